@@ -0,0 +1,581 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"maxiputz/motisConfigServer/provider"
+	"maxiputz/motisConfigServer/scrapper"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Source names one of the catalogs offered to the UI.
+type Source string
+
+const (
+	Geofabrik  Source = "geofabrik"
+	Transitous Source = "transitous"
+	Motis      Source = "motis"
+)
+
+// Sources lists all catalogs in a stable order.
+var Sources = []Source{Geofabrik, Transitous, Motis}
+
+// Origin tells where the currently served data of a catalog came from.
+type Origin string
+
+const (
+	OriginLive     Origin = "live"
+	OriginCache    Origin = "cache"
+	OriginEmbedded Origin = "embedded"
+)
+
+// State summarizes the health of a catalog.
+type State string
+
+const (
+	// StateOK is fresh data from the last successful fetch.
+	StateOK State = "ok"
+	// StateStale is data older than its TTL, e.g. while offline.
+	StateStale State = "stale"
+	// StateError means the last refresh failed, older data is served.
+	StateError State = "error"
+)
+
+// Status describes the data currently served for one catalog.
+type Status struct {
+	Source    Source     `json:"source"`
+	State     State      `json:"state"`
+	Origin    Origin     `json:"origin"`
+	FetchedAt *time.Time `json:"fetchedAt,omitempty"`
+	// Age is the time since FetchedAt, empty if the fetch time is unknown.
+	Age string `json:"age,omitempty"`
+	TTL string `json:"ttl"`
+	// Stale is true if the data is older than its TTL or of unknown age.
+	Stale      bool `json:"stale"`
+	Refreshing bool `json:"refreshing"`
+	// Progress describes a running refresh, e.g. the crawled pages.
+	Progress string `json:"progress,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// Warning describes parts missing from the last refresh, e.g. region
+	// pages that could not be crawled.
+	Warning string `json:"warning,omitempty"`
+	// Message explains a stale or error state for display.
+	Message string `json:"message,omitempty"`
+}
+
+type sourceDef struct {
+	file string
+	// fetch returns the catalog and a warning about missing parts. It
+	// reports progress while it runs.
+	fetch  func(ctx context.Context, p *provider.Set, progress func(string)) (any, string, error)
+	hosts  func(*provider.Set) []string
+	decode func([]byte) (any, error)
+}
+
+// fetchRegions crawls the region tree and summarizes the pages that could
+// not be fetched.
+func fetchRegions(ctx context.Context, p *provider.Set, progress func(string)) (any, string, error) {
+	result, err := p.Regions(ctx, scrapper.CrawlOptions{
+		Progress: func(cp scrapper.CrawlProgress) {
+			progress(fmt.Sprintf("%d pages fetched, %d remaining", cp.Fetched, cp.Remaining))
+		},
+	})
+	if err != nil || len(result.Failures) == 0 {
+		return result.Tree, "", err
+	}
+	paths := []string{}
+	for _, failure := range result.Failures {
+		paths = append(paths, failure.Path)
+	}
+	sort.Strings(paths)
+	if len(paths) > maxWarningPaths {
+		paths = append(paths[:maxWarningPaths], "...")
+	}
+	warning := fmt.Sprintf("%d region pages could not be fetched, their subregions are missing: %s", len(result.Failures), strings.Join(paths, ", "))
+	return result.Tree, warning, nil
+}
+
+// maxWarningPaths bounds the failed pages listed in a warning.
+const maxWarningPaths = 10
+
+func decodeJSON[T any](data []byte) (any, error) {
+	var v T
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+// decodeReleases also sorts the releases, as older caches kept the order of
+// the GitHub API.
+func decodeReleases(data []byte) (any, error) {
+	var releases []scrapper.Release
+	if err := json.Unmarshal(data, &releases); err != nil {
+		return nil, err
+	}
+	scrapper.SortReleases(releases)
+	return releases, nil
+}
+
+var sourceDefs = map[Source]sourceDef{
+	Geofabrik: {
+		file:   "geofabrik.json",
+		fetch:  fetchRegions,
+		hosts:  (*provider.Set).OSMHosts,
+		decode: decodeJSON[scrapper.Region],
+	},
+	Transitous: {
+		file: "gtfs.json",
+		fetch: func(ctx context.Context, p *provider.Set, _ func(string)) (any, string, error) {
+			feeds, err := p.Feeds(ctx)
+			return feeds, "", err
+		},
+		hosts:  (*provider.Set).FeedHosts,
+		decode: decodeJSON[[]scrapper.Transitous],
+	},
+	Motis: {
+		file: "motis.json",
+		fetch: func(ctx context.Context, p *provider.Set, _ func(string)) (any, string, error) {
+			releases, err := p.Releases(ctx)
+			return releases, "", err
+		},
+		hosts:  (*provider.Set).ReleaseHosts,
+		decode: decodeReleases,
+	},
+}
+
+// Catalog holds the OSM regions, GTFS feeds and MOTIS releases. It starts
+// from the workspace store or the snapshots embedded in the binary and is
+// refreshed from its providers when they are reachable.
+type Catalog struct {
+	mu         sync.RWMutex
+	embedded   fs.FS
+	store      *Store
+	providers  *provider.Set
+	ttl        map[Source]time.Duration
+	data       map[Source]any
+	status     map[Source]Status
+	refreshing map[Source]bool
+	listeners  []func(Source)
+	onChange   []func(ChangeSet)
+	// changesMu serializes access to the change history of the store.
+	changesMu sync.Mutex
+}
+
+// New creates a catalog fetching from the default providers, see
+// provider.DefaultConfig. embedded holds the snapshot files at its root,
+// store keeps live results for the next start.
+func New(embedded fs.FS, store *Store) *Catalog {
+	ttl := map[Source]time.Duration{}
+	for source, d := range DefaultTTL {
+		ttl[source] = d
+	}
+	providers, err := provider.Build(provider.DefaultConfig)
+	if err != nil {
+		log.Printf("Error creating the default providers: %v", err)
+	}
+	return &Catalog{
+		embedded:   embedded,
+		store:      store,
+		providers:  providers,
+		ttl:        ttl,
+		data:       map[Source]any{},
+		status:     map[Source]Status{},
+		refreshing: map[Source]bool{},
+	}
+}
+
+// UseProviders replaces the providers the catalogs are refreshed from. The
+// data already loaded is served until the next refresh.
+func (c *Catalog) UseProviders(providers *provider.Set) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.providers = providers
+}
+
+func (c *Catalog) currentProviders() *provider.Set {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.providers
+}
+
+// SetTTL changes how long fetched data of source is considered fresh.
+func (c *Catalog) SetTTL(source Source, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl[source] = ttl
+}
+
+// LoadOffline fills every catalog without network access, preferring the
+// stored catalog over the embedded snapshot. The embedded snapshots carry
+// no fetch time, so they count as stale until refreshed. A source that
+// cannot be loaded is marked as failed, the others are loaded regardless.
+func (c *Catalog) LoadOffline() error {
+	var errs []error
+	for _, source := range Sources {
+		if err := c.loadOffline(source); err != nil {
+			c.setError(source, err)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Catalog) loadOffline(source Source) error {
+	def := sourceDefs[source]
+
+	record, err := c.store.Load(source)
+	if err == nil {
+		value, err := def.decode(record.Data)
+		if err == nil {
+			c.set(source, value, OriginCache, record.FetchedAt)
+			return nil
+		}
+		log.Printf("Ignoring stored %s catalog: %v", source, err)
+	} else if !os.IsNotExist(err) {
+		log.Printf("Ignoring stored %s catalog: %v", source, err)
+	}
+
+	data, err := fs.ReadFile(c.embedded, def.file)
+	if err != nil {
+		return fmt.Errorf("no embedded snapshot for %s: %w", source, err)
+	}
+	value, err := def.decode(data)
+	if err != nil {
+		return fmt.Errorf("error parsing embedded %s snapshot: %w", source, err)
+	}
+	c.set(source, value, OriginEmbedded, time.Time{})
+	return nil
+}
+
+// OnUpdate registers fn to be called after the data of a source changed.
+func (c *Catalog) OnUpdate(fn func(Source)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.listeners = append(c.listeners, fn)
+}
+
+// OnChange registers fn to be called with the changes of a refresh that
+// added, removed or renamed entries.
+func (c *Catalog) OnChange(fn func(ChangeSet)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onChange = append(c.onChange, fn)
+}
+
+func (c *Catalog) set(source Source, value any, origin Origin, fetchedAt time.Time) {
+	c.mu.Lock()
+	c.data[source] = value
+	status := Status{Source: source, Origin: origin}
+	if !fetchedAt.IsZero() {
+		status.FetchedAt = &fetchedAt
+	}
+	c.status[source] = status
+	listeners := c.listeners
+	c.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(source)
+	}
+}
+
+// Online reports whether every host the providers of source fetch from is
+// reachable. Sources read from local files are always online.
+func (c *Catalog) Online(source Source) bool {
+	def, ok := sourceDefs[source]
+	if !ok {
+		return false
+	}
+	for _, host := range def.hosts(c.currentProviders()) {
+		conn, err := net.DialTimeout("tcp", host, 3*time.Second)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+	}
+	return true
+}
+
+// ParseSource validates a source name.
+func ParseSource(name string) (Source, error) {
+	if _, ok := sourceDefs[Source(name)]; !ok {
+		return "", fmt.Errorf("unknown catalog source %q", name)
+	}
+	return Source(name), nil
+}
+
+// Refresh fetches source from the network and saves it in the store.
+// The previous data is served until the fetch succeeds; on failure it is
+// kept and the error is recorded.
+func (c *Catalog) Refresh(ctx context.Context, source Source) error {
+	if _, ok := sourceDefs[source]; !ok {
+		return fmt.Errorf("unknown catalog source %q", source)
+	}
+	if !c.startRefresh(source) {
+		return fmt.Errorf("refresh of %s already running", source)
+	}
+	defer c.finishRefresh(source)
+	return c.refresh(ctx, source)
+}
+
+// RefreshInBackground starts Refresh for source unless one is running.
+// It reports whether a refresh was started.
+func (c *Catalog) RefreshInBackground(source Source) bool {
+	if _, ok := sourceDefs[source]; !ok || !c.startRefresh(source) {
+		return false
+	}
+	go func() {
+		defer c.finishRefresh(source)
+		if err := c.refresh(context.Background(), source); err != nil {
+			log.Printf("Error refreshing %s: %v", source, err)
+		}
+	}()
+	return true
+}
+
+func (c *Catalog) refresh(ctx context.Context, source Source) error {
+	value, warning, err := sourceDefs[source].fetch(ctx, c.currentProviders(), func(progress string) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		status := c.status[source]
+		status.Progress = progress
+		c.status[source] = status
+	})
+	if err != nil {
+		c.setError(source, err)
+		return err
+	}
+	fetchedAt := time.Now()
+	if warning != "" {
+		log.Printf("Refreshed %s with gaps: %s", source, warning)
+	}
+
+	c.mu.RLock()
+	ttl := c.ttl[source]
+	old, hadOld := c.data[source]
+	oldStatus := c.status[source]
+	c.mu.RUnlock()
+
+	c.set(source, value, OriginLive, fetchedAt)
+	c.mu.Lock()
+	status := c.status[source]
+	status.Warning = warning
+	c.status[source] = status
+	c.mu.Unlock()
+	if hadOld {
+		c.recordChanges(source, old, oldStatus, value, fetchedAt, ttl)
+	}
+
+	if err := c.store.Save(source, value, fetchedAt, ttl); err != nil {
+		log.Printf("Error storing %s catalog: %v", source, err)
+	}
+	return nil
+}
+
+// recordChanges archives the previous data of source and adds the diff to
+// the change history if anything changed. If the entry keys changed
+// format, see sameFormat, the previous data is archived but not diffed.
+func (c *Catalog) recordChanges(source Source, old any, oldStatus Status, value any, fetchedAt time.Time, ttl time.Duration) {
+	archivedAt := time.Time{}
+	if oldStatus.FetchedAt != nil {
+		archivedAt = *oldStatus.FetchedAt
+	}
+
+	if !sameFormat(source, old, value) {
+		c.changesMu.Lock()
+		archive, err := c.store.Archive(source, old, archivedAt, ttl)
+		c.changesMu.Unlock()
+		if err != nil {
+			log.Printf("Error archiving %s catalog: %v", source, err)
+			return
+		}
+		log.Printf("Not diffing the %s catalog, its entries are keyed differently than before, previous data archived to %s", source, archive)
+		return
+	}
+	changes := Diff(source, old, value)
+	if changes.Empty() {
+		return
+	}
+	changes.To = fetchedAt
+	changes.From = oldStatus.FetchedAt
+
+	c.changesMu.Lock()
+	archive, err := c.store.Archive(source, old, archivedAt, ttl)
+	if err != nil {
+		log.Printf("Error archiving %s catalog: %v", source, err)
+	}
+	changes.Archive = archive
+	if err := c.store.AddChanges(changes); err != nil {
+		log.Printf("Error storing %s changes: %v", source, err)
+	}
+	c.changesMu.Unlock()
+	log.Printf("%s catalog changed: %d added, %d removed, %d renamed", source, len(changes.Added), len(changes.Removed), len(changes.Renamed))
+
+	c.mu.RLock()
+	listeners := c.onChange
+	c.mu.RUnlock()
+	for _, fn := range listeners {
+		fn(changes)
+	}
+}
+
+// Changes returns the recorded changes of source, or of all sources if
+// source is empty, newer than since, newest first.
+func (c *Catalog) Changes(source Source, since time.Time) ([]ChangeSet, error) {
+	c.changesMu.Lock()
+	history, err := c.store.Changes()
+	c.changesMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	result := []ChangeSet{}
+	for _, changes := range history {
+		if (source == "" || changes.Source == source) && changes.To.After(since) {
+			result = append(result, changes)
+		}
+	}
+	return result, nil
+}
+
+// RefreshStale refreshes every catalog that is older than its TTL and
+// whose host is reachable.
+func (c *Catalog) RefreshStale(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, source := range Sources {
+		if !c.stale(source) {
+			continue
+		}
+		if !c.Online(source) {
+			log.Printf("Skipping refresh of %s: offline", source)
+			continue
+		}
+		wg.Add(1)
+		go func(source Source) {
+			defer wg.Done()
+			if err := c.Refresh(ctx, source); err != nil {
+				log.Printf("Error refreshing %s: %v", source, err)
+			}
+		}(source)
+	}
+	wg.Wait()
+}
+
+func (c *Catalog) startRefresh(source Source) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.refreshing[source] {
+		return false
+	}
+	c.refreshing[source] = true
+	return true
+}
+
+func (c *Catalog) finishRefresh(source Source) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.refreshing, source)
+	status := c.status[source]
+	status.Progress = ""
+	c.status[source] = status
+}
+
+func (c *Catalog) stale(source Source) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.staleLocked(source)
+}
+
+func (c *Catalog) staleLocked(source Source) bool {
+	status := c.status[source]
+	return status.FetchedAt == nil || time.Since(*status.FetchedAt) > c.ttl[source]
+}
+
+func (c *Catalog) setError(source Source, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	status := c.status[source]
+	status.Source = source
+	status.Error = err.Error()
+	status.Progress = ""
+	c.status[source] = status
+}
+
+// Region returns the Geofabrik region tree.
+func (c *Catalog) Region() scrapper.Region {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	region, _ := c.data[Geofabrik].(scrapper.Region)
+	return region
+}
+
+// Transitous returns the Transitous feed list.
+func (c *Catalog) Transitous() []scrapper.Transitous {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	feeds, _ := c.data[Transitous].([]scrapper.Transitous)
+	return feeds
+}
+
+// Releases returns the MOTIS releases.
+func (c *Catalog) Releases() []scrapper.Release {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	releases, _ := c.data[Motis].([]scrapper.Release)
+	return releases
+}
+
+// Statuses returns the status of every catalog in the order of Sources.
+func (c *Catalog) Statuses() []Status {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	result := []Status{}
+	for _, source := range Sources {
+		status := c.status[source]
+		status.Source = source
+		if status.FetchedAt != nil {
+			status.Age = time.Since(*status.FetchedAt).Round(time.Second).String()
+		}
+		status.TTL = c.ttl[source].String()
+		status.Stale = c.staleLocked(source)
+		status.Refreshing = c.refreshing[source]
+		switch {
+		case status.Error != "":
+			status.State = StateError
+			status.Message = fmt.Sprintf("refresh failed, serving %s data: %s", status.Origin, status.Error)
+		case status.Stale && status.FetchedAt == nil:
+			status.State = StateStale
+			status.Message = fmt.Sprintf("serving %s data of unknown age", status.Origin)
+		case status.Stale:
+			status.State = StateStale
+			status.Message = fmt.Sprintf("%s data fetched at %s is older than %s", status.Origin, status.FetchedAt.Format(time.RFC3339), status.TTL)
+		default:
+			status.State = StateOK
+		}
+		if status.Warning != "" {
+			if status.Message != "" {
+				status.Message += "; "
+			}
+			status.Message += status.Warning
+		}
+		result = append(result, status)
+	}
+	return result
+}
+
+// SourceStatuses returns the statuses without Age, which changes with every
+// call. Clients compute the age from FetchedAt, so responses holding them
+// stay cacheable.
+func (c *Catalog) SourceStatuses() []Status {
+	statuses := c.Statuses()
+	for i := range statuses {
+		statuses[i].Age = ""
+	}
+	return statuses
+}
@@ -0,0 +1,49 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"maxiputz/motisConfigServer/provider"
+	"maxiputz/motisConfigServer/scrapper"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// rateLimited is a release provider throttled by GitHub, returning cached
+// releases with the error like scrapper.FetchRepo.
+type rateLimited struct{}
+
+func (rateLimited) Name() string    { return "github" }
+func (rateLimited) Hosts() []string { return nil }
+func (rateLimited) Releases(ctx context.Context) ([]scrapper.Release, error) {
+	return []scrapper.Release{{TagName: "v1"}}, &scrapper.RateLimitError{Reset: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func TestRefreshKeepsDataWhileRateLimited(t *testing.T) {
+	embedded, _ := json.Marshal([]scrapper.Release{{TagName: "v2"}, {TagName: "v1"}})
+	catalogs := New(fstest.MapFS{"motis.json": {Data: embedded}}, NewStore(t.TempDir()))
+	catalogs.LoadOffline()
+	catalogs.UseProviders(&provider.Set{ReleaseProviders: []provider.ReleaseProvider{rateLimited{}}})
+
+	err := catalogs.Refresh(context.Background(), Motis)
+	if !scrapper.IsRateLimited(err) {
+		t.Fatalf("Refresh() error = %v, want a rate limit error", err)
+	}
+	if releases := catalogs.Releases(); len(releases) != 2 {
+		t.Errorf("releases = %+v, want the two embedded releases", releases)
+	}
+	for _, status := range catalogs.Statuses() {
+		if status.Source != Motis {
+			continue
+		}
+		if status.State != StateError || status.Origin != OriginEmbedded || !strings.Contains(status.Message, "resets at 2025-01-01T12:00:00Z") {
+			t.Errorf("status = %+v, want an error with the reset time on the embedded data", status)
+		}
+	}
+	changes, err := catalogs.Changes(Motis, time.Time{})
+	if err == nil && len(changes) != 0 {
+		t.Errorf("changes = %+v, want none", changes)
+	}
+}
@@ -0,0 +1,140 @@
+package catalog
+
+import (
+	"maxiputz/motisConfigServer/scrapper"
+	"sort"
+	"time"
+)
+
+// Change is one entry added to, removed from or renamed in a catalog.
+// Entries are regions, feeds or releases, identified by their region key,
+// feed URL or release tag.
+type Change struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// OldID and OldName are set for renames. A rename keeps the ID and
+	// changes the name, or keeps the name under a new ID, e.g. a feed
+	// moved to another URL.
+	OldID   string `json:"oldId,omitempty"`
+	OldName string `json:"oldName,omitempty"`
+}
+
+// ChangeSet describes how a catalog changed with one refresh.
+type ChangeSet struct {
+	Source Source `json:"source"`
+	// From is the fetch time of the previous data, nil if unknown.
+	From *time.Time `json:"from,omitempty"`
+	To   time.Time  `json:"to"`
+	// Archive is the stored file holding the previous catalog.
+	Archive string   `json:"archive,omitempty"`
+	Added   []Change `json:"added"`
+	Removed []Change `json:"removed"`
+	Renamed []Change `json:"renamed"`
+}
+
+// Empty reports whether nothing changed.
+func (c ChangeSet) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Renamed) == 0
+}
+
+// entries lists the identified entries of a catalog value.
+func entries(source Source, value any) []Change {
+	result := []Change{}
+	switch source {
+	case Geofabrik:
+		region, _ := value.(scrapper.Region)
+		for _, r := range region.Flatten() {
+			result = append(result, Change{ID: r.ID, Name: r.RegionName})
+		}
+	case Transitous:
+		feeds, _ := value.([]scrapper.Transitous)
+		for _, feed := range feeds {
+			result = append(result, Change{ID: feed.Url, Name: feed.Name})
+		}
+	case Motis:
+		releases, _ := value.([]scrapper.Release)
+		for _, release := range releases {
+			result = append(result, Change{ID: release.TagName, Name: release.Name})
+		}
+	}
+	return result
+}
+
+// sameFormat reports whether the entries of old and new are keyed alike.
+// Regions read from the Geofabrik index are keyed by id and scraped ones by
+// PBF path, so diffing across formats would report every region as moved.
+func sameFormat(source Source, old, new any) bool {
+	if source != Geofabrik {
+		return true
+	}
+	return fromIndex(old) == fromIndex(new)
+}
+
+// fromIndex reports whether a region tree was read from the Geofabrik index.
+func fromIndex(value any) bool {
+	region, _ := value.(scrapper.Region)
+	for _, child := range region.Children {
+		if child.ID != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff compares two versions of a catalog.
+func Diff(source Source, old, new any) ChangeSet {
+	changes := ChangeSet{Source: source, Added: []Change{}, Removed: []Change{}, Renamed: []Change{}}
+
+	oldByID := map[string]Change{}
+	for _, e := range entries(source, old) {
+		oldByID[e.ID] = e
+	}
+	newByID := map[string]Change{}
+	for _, e := range entries(source, new) {
+		newByID[e.ID] = e
+	}
+
+	for id, e := range newByID {
+		before, ok := oldByID[id]
+		switch {
+		case !ok:
+			changes.Added = append(changes.Added, e)
+		case before.Name != e.Name:
+			changes.Renamed = append(changes.Renamed, Change{ID: id, Name: e.Name, OldID: id, OldName: before.Name})
+		}
+	}
+	for id, e := range oldByID {
+		if _, ok := newByID[id]; !ok {
+			changes.Removed = append(changes.Removed, e)
+		}
+	}
+
+	// Pair removed and added entries with the same name as moves.
+	removedByName := map[string]int{}
+	for i, e := range changes.Removed {
+		removedByName[e.Name] = i
+	}
+	moved := map[int]bool{}
+	added := []Change{}
+	for _, e := range changes.Added {
+		i, ok := removedByName[e.Name]
+		if !ok || e.Name == "" || moved[i] {
+			added = append(added, e)
+			continue
+		}
+		moved[i] = true
+		changes.Renamed = append(changes.Renamed, Change{ID: e.ID, Name: e.Name, OldID: changes.Removed[i].ID, OldName: e.Name})
+	}
+	removed := []Change{}
+	for i, e := range changes.Removed {
+		if !moved[i] {
+			removed = append(removed, e)
+		}
+	}
+	changes.Added, changes.Removed = added, removed
+
+	for _, list := range [][]Change{changes.Added, changes.Removed, changes.Renamed} {
+		sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	}
+	return changes
+}
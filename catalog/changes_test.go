@@ -0,0 +1,112 @@
+package catalog
+
+import (
+	"maxiputz/motisConfigServer/scrapper"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  Source
+		old     any
+		new     any
+		added   []Change
+		removed []Change
+		renamed []Change
+	}{
+		{
+			name:   "unchanged",
+			source: Motis,
+			old:    []scrapper.Release{{TagName: "v1", Name: "One"}},
+			new:    []scrapper.Release{{TagName: "v1", Name: "One"}},
+		},
+		{
+			name:    "added and removed",
+			source:  Motis,
+			old:     []scrapper.Release{{TagName: "v1", Name: "One"}, {TagName: "v2", Name: "Two"}},
+			new:     []scrapper.Release{{TagName: "v2", Name: "Two"}, {TagName: "v3", Name: "Three"}},
+			added:   []Change{{ID: "v3", Name: "Three"}},
+			removed: []Change{{ID: "v1", Name: "One"}},
+		},
+		{
+			name:    "renamed under the same id",
+			source:  Motis,
+			old:     []scrapper.Release{{TagName: "v1", Name: "One"}},
+			new:     []scrapper.Release{{TagName: "v1", Name: "First"}},
+			renamed: []Change{{ID: "v1", Name: "First", OldID: "v1", OldName: "One"}},
+		},
+		{
+			name:    "feed moved to another url",
+			source:  Transitous,
+			old:     []scrapper.Transitous{{Name: "de_db.gtfs.zip", Url: "https://a/de_db.gtfs.zip"}},
+			new:     []scrapper.Transitous{{Name: "de_db.gtfs.zip", Url: "https://b/de_db.gtfs.zip"}},
+			renamed: []Change{{ID: "https://b/de_db.gtfs.zip", Name: "de_db.gtfs.zip", OldID: "https://a/de_db.gtfs.zip", OldName: "de_db.gtfs.zip"}},
+		},
+		{
+			name:   "nested regions",
+			source: Geofabrik,
+			old: scrapper.Region{Children: []scrapper.Region{
+				{ID: "europe", RegionName: "Europe", Children: []scrapper.Region{{ID: "germany", RegionName: "Germany"}}},
+			}},
+			new: scrapper.Region{Children: []scrapper.Region{
+				{ID: "europe", RegionName: "Europe", Children: []scrapper.Region{{ID: "germany", RegionName: "Germany"}, {ID: "france", RegionName: "France"}}},
+			}},
+			added: []Change{{ID: "france", Name: "France"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := Diff(tt.source, tt.old, tt.new)
+			if changes.Source != tt.source {
+				t.Errorf("Source = %q, want %q", changes.Source, tt.source)
+			}
+			for _, list := range []struct {
+				name      string
+				got, want []Change
+			}{
+				{"Added", changes.Added, tt.added},
+				{"Removed", changes.Removed, tt.removed},
+				{"Renamed", changes.Renamed, tt.renamed},
+			} {
+				want := list.want
+				if want == nil {
+					want = []Change{}
+				}
+				if !reflect.DeepEqual(list.got, want) {
+					t.Errorf("%s = %+v, want %+v", list.name, list.got, want)
+				}
+			}
+			if empty := tt.added == nil && tt.removed == nil && tt.renamed == nil; changes.Empty() != empty {
+				t.Errorf("Empty() = %v, want %v", changes.Empty(), empty)
+			}
+		})
+	}
+}
+
+func TestSameFormat(t *testing.T) {
+	index := scrapper.Region{Children: []scrapper.Region{{ID: "europe", Path: "europe.html"}}}
+	scraped := scrapper.Region{Children: []scrapper.Region{{Path: "europe.html"}}}
+	tests := []struct {
+		name     string
+		source   Source
+		old, new any
+		want     bool
+	}{
+		{"index to index", Geofabrik, index, index, true},
+		{"scraped to scraped", Geofabrik, scraped, scraped, true},
+		{"scraped to index", Geofabrik, scraped, index, false},
+		{"index to scraped", Geofabrik, index, scraped, false},
+		{"empty tree", Geofabrik, scrapper.Region{}, scraped, true},
+		{"feeds", Transitous, []scrapper.Transitous{}, []scrapper.Transitous{{Url: "u"}}, true},
+		{"releases", Motis, []scrapper.Release{}, []scrapper.Release{{TagName: "v1"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameFormat(tt.source, tt.old, tt.new); got != tt.want {
+				t.Errorf("sameFormat() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DefaultTTL is how long a fetched catalog is considered fresh.
+var DefaultTTL = map[Source]time.Duration{
+	Geofabrik:  24 * time.Hour,
+	Transitous: 6 * time.Hour,
+	Motis:      6 * time.Hour,
+}
+
+// Store keeps the last fetched version of each catalog in a directory of
+// the workspace, one JSON file per source.
+type Store struct {
+	Dir string
+}
+
+// Record is a stored catalog together with its fetch time and TTL.
+type Record struct {
+	Source    Source          `json:"source"`
+	FetchedAt time.Time       `json:"fetchedAt"`
+	TTL       time.Duration   `json:"ttl"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Expired reports whether the record is older than its TTL.
+func (r Record) Expired(now time.Time) bool {
+	return now.Sub(r.FetchedAt) > r.TTL
+}
+
+// NewStore creates a store writing to dir.
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+func (s *Store) path(source Source) string {
+	return filepath.Join(s.Dir, string(source)+".json")
+}
+
+// Load reads the stored record of source.
+func (s *Store) Load(source Source) (Record, error) {
+	record := Record{}
+	data, err := os.ReadFile(s.path(source))
+	if err != nil {
+		return record, err
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return record, fmt.Errorf("error parsing %s: %w", s.path(source), err)
+	}
+	return record, nil
+}
+
+// Save stores value as the current version of source.
+func (s *Store) Save(source Source, value any, fetchedAt time.Time, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	record, err := json.MarshalIndent(Record{
+		Source:    source,
+		FetchedAt: fetchedAt,
+		TTL:       ttl,
+		Data:      data,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see a partial record.
+	tmpFile := s.path(source) + ".tmp"
+	if err := os.WriteFile(tmpFile, record, 0664); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, s.path(source))
+}
+
+// maxChanges bounds the change history kept in the store, and the number of
+// archived catalogs with it.
+const maxChanges = 200
+
+// Archive stores a previous version of source in the archive directory and
+// returns the path of the archive file. Only the newest maxChanges archives
+// are kept.
+func (s *Store) Archive(source Source, value any, fetchedAt time.Time, ttl time.Duration) (string, error) {
+	archive := &Store{Dir: filepath.Join(s.Dir, "archive")}
+	stamp := time.Now().UTC().Format("20060102T150405.000000000Z")
+	name := Source(fmt.Sprintf("%s-%s", source, stamp))
+	// Two archives within the clock resolution get numbered names.
+	for i := 1; ; i++ {
+		if _, err := os.Stat(archive.path(name)); os.IsNotExist(err) {
+			break
+		}
+		name = Source(fmt.Sprintf("%s-%s-%d", source, stamp, i))
+	}
+	if err := archive.Save(name, value, fetchedAt, ttl); err != nil {
+		return "", err
+	}
+	if err := archive.prune(maxChanges); err != nil {
+		log.Printf("Error pruning catalog archives: %v", err)
+	}
+	return archive.path(name), nil
+}
+
+// prune removes all but the newest keep records of the store.
+func (s *Store) prune(keep int) error {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return err
+	}
+	type record struct {
+		name    string
+		modTime time.Time
+	}
+	records := []record{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		records = append(records, record{entry.Name(), info.ModTime()})
+	}
+	if len(records) <= keep {
+		return nil
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].modTime.Equal(records[j].modTime) {
+			return records[i].modTime.After(records[j].modTime)
+		}
+		return records[i].name > records[j].name
+	})
+	for _, r := range records[keep:] {
+		if err := os.Remove(filepath.Join(s.Dir, r.name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Changes returns the stored change history, newest first.
+func (s *Store) Changes() ([]ChangeSet, error) {
+	changes := []ChangeSet{}
+	data, err := os.ReadFile(filepath.Join(s.Dir, "changes.json"))
+	if os.IsNotExist(err) {
+		return changes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &changes); err != nil {
+		return nil, fmt.Errorf("error parsing changes.json: %w", err)
+	}
+	return changes, nil
+}
+
+// AddChanges prepends a change set to the history.
+func (s *Store) AddChanges(changes ChangeSet) error {
+	history, err := s.Changes()
+	if err != nil {
+		return err
+	}
+	history = append([]ChangeSet{changes}, history...)
+	if len(history) > maxChanges {
+		history = history[:maxChanges]
+	}
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(s.Dir, "changes.json")
+	if err := os.WriteFile(path+".tmp", data, 0664); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
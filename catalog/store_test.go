@@ -0,0 +1,31 @@
+package catalog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestArchiveNamesAndRetention(t *testing.T) {
+	store := NewStore(t.TempDir())
+	paths := map[string]bool{}
+	for i := 0; i < maxChanges+5; i++ {
+		path, err := store.Archive(Motis, []string{"v1"}, time.Time{}, time.Hour)
+		if err != nil {
+			t.Fatalf("Archive() error = %v", err)
+		}
+		if paths[path] {
+			t.Fatalf("Archive() reused %s", path)
+		}
+		paths[path] = true
+	}
+
+	entries, err := os.ReadDir(filepath.Join(store.Dir, "archive"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != maxChanges {
+		t.Errorf("kept %d archives, want %d", len(entries), maxChanges)
+	}
+}
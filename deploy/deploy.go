@@ -0,0 +1,181 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	motisconfigfile "maxiputz/motisConfigServer/motisConfigFile"
+)
+
+// Params describe the MOTIS setup the deployment artifacts are generated for.
+type Params struct {
+	// WorkDir is the workspace holding config.yml, the data files and the
+	// motis binary. The artifacts are written into it.
+	WorkDir string
+	// HostDir is WorkDir as seen on the host that runs the artifacts, e.g.
+	// the mounted directory when this server runs in docker. It defaults
+	// to the absolute WorkDir.
+	HostDir string
+	// Release is the MOTIS release tag, e.g. v2.6.1.
+	Release string
+	// Binary is the motis binary relative to WorkDir, e.g. the installed
+	// version versions/v2.6.1/motis. It defaults to motis, the active one.
+	Binary string
+	Server motisconfigfile.ServerOptions
+	// User runs the systemd service; empty keeps the systemd default.
+	User string
+	// Image is the docker image containing the motis binary.
+	Image string
+}
+
+// Artifact is a generated file relative to the workspace.
+type Artifact struct {
+	Name string      `json:"name"`
+	Mode os.FileMode `json:"mode"`
+	tmpl *template.Template
+}
+
+var funcs = template.FuncMap{
+	"systemdEscape": systemdEscape,
+	"systemdQuote":  systemdQuote,
+	"shellQuote":    shellQuote,
+	"yamlQuote":     strconv.Quote,
+}
+
+var artifacts = []Artifact{
+	{Name: "import.sh", Mode: 0755, tmpl: template.Must(template.New("import.sh").Funcs(funcs).Parse(importScript))},
+	{Name: "serve.sh", Mode: 0755, tmpl: template.Must(template.New("serve.sh").Funcs(funcs).Parse(serveScript))},
+	{Name: "motis.service", Mode: 0644, tmpl: template.Must(template.New("motis.service").Funcs(funcs).Parse(systemdUnit))},
+	{Name: "docker-compose.motis.yml", Mode: 0644, tmpl: template.Must(template.New("docker-compose.motis.yml").Funcs(funcs).Parse(composeFile))},
+}
+
+// systemdEscape escapes the specifiers in a unit file setting.
+func systemdEscape(s string) string {
+	return strings.ReplaceAll(s, "%", "%%")
+}
+
+// systemdQuote quotes a word of an ExecStart command line, escaping
+// specifiers, backslashes and quotes.
+func systemdQuote(s string) string {
+	s = systemdEscape(s)
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// shellQuote quotes a word of a sh command line.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+const importScript = `#!/bin/sh
+# Imports the OSM and GTFS data for MOTIS {{.Release}}.
+set -e
+cd "$(dirname "$0")"
+{{shellQuote (print "./" .Binary)}} import
+`
+
+const serveScript = `#!/bin/sh
+# Serves MOTIS {{.Release}} on {{.Server.Host}}:{{.Server.Port}}.
+set -e
+cd "$(dirname "$0")"
+exec {{shellQuote (print "./" .Binary)}} server
+`
+
+const systemdUnit = `[Unit]
+Description=MOTIS {{systemdEscape .Release}} server
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+{{- if .User}}
+User={{.User}}
+{{- end}}
+WorkingDirectory={{systemdEscape .HostDir}}
+ExecStart={{systemdQuote (print .HostDir "/" .Binary)}} server
+Restart=always
+RestartSec=5
+LimitNOFILE=65536
+
+[Install]
+WantedBy=multi-user.target
+`
+
+const composeFile = `# MOTIS {{.Release}}
+services:
+  motis-import:
+    image: {{.Image}}
+    volumes:
+      - {{yamlQuote (print .HostDir ":/app/out")}}
+    working_dir: /app/out
+    command: [{{yamlQuote (print "./" .Binary)}}, "import"]
+    restart: "no"
+
+  motis-server:
+    image: {{.Image}}
+    depends_on:
+      motis-import:
+        condition: service_completed_successfully
+    volumes:
+      - {{yamlQuote (print .HostDir ":/app/out")}}
+    working_dir: /app/out
+    ports:
+      - "{{.Server.Port}}:{{.Server.Port}}"
+    command: [{{yamlQuote (print "./" .Binary)}}, "server"]
+    restart: always
+`
+
+// Generate writes the import and serve scripts, the systemd unit and the
+// docker-compose file into params.WorkDir and returns the written artifacts.
+func Generate(params Params) ([]Artifact, error) {
+	workDir, err := filepath.Abs(params.WorkDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve work dir: %w", err)
+	}
+	params.WorkDir = workDir
+	if params.HostDir == "" {
+		params.HostDir = workDir
+	}
+	params.HostDir = strings.TrimSuffix(params.HostDir, "/")
+	params.Server = params.Server.WithDefaults()
+	// The release is written into comments, keep it on one line.
+	params.Release = strings.Join(strings.Fields(params.Release), " ")
+	if params.Release == "" {
+		params.Release = "unknown release"
+	}
+	if params.Binary == "" {
+		params.Binary = "motis"
+	}
+	params.Binary = filepath.ToSlash(params.Binary)
+	if params.Image == "" {
+		params.Image = "motis:latest"
+	}
+
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create work dir: %w", err)
+	}
+
+	for _, artifact := range artifacts {
+		target := filepath.Join(workDir, artifact.Name)
+		file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, artifact.Mode)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", target, err)
+		}
+		err = artifact.tmpl.Execute(file, params)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", target, err)
+		}
+		// OpenFile only applies the mode to new files.
+		if err := os.Chmod(target, artifact.Mode); err != nil {
+			return nil, fmt.Errorf("failed to chmod %s: %w", target, err)
+		}
+	}
+
+	return artifacts, nil
+}
@@ -5,11 +5,14 @@ import (
 	"compress/bzip2"
 	"fmt"
 	"io"
+	motisconfigfile "maxiputz/motisConfigServer/motisConfigFile"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 )
 
@@ -18,11 +21,75 @@ type RequestDownload struct {
 	GTFSURLs []string `json:"gtfsUrls"`
 	OsmURL   string   `json:"osmUrl"`
 	MotisUrl string   `json:"motisUrl"`
+	// Profile names the motisConfigFile profile used to generate config.yml.
+	Profile string `json:"profile"`
+	// GTFSOptions holds per-feed dataset options keyed by GTFS URL.
+	GTFSOptions map[string]motisconfigfile.DatasetOptions `json:"gtfsOptions,omitempty"`
+	// IncludeRealtime adds the GTFS-RT endpoints known from the feed
+	// catalog to datasets without explicit RealtimeURLs.
+	IncludeRealtime bool `json:"includeRealtime"`
+	// Server holds the listen settings written to the server section.
+	Server motisconfigfile.ServerOptions `json:"server"`
 }
 
-// extractFileName returns the base file name from a URL.
-func extractFileName(url string) string {
-	return path.Base(url)
+// Validate checks the server and per-feed options of the request.
+func (r RequestDownload) Validate() error {
+	if err := r.Server.Validate(); err != nil {
+		return err
+	}
+	for url, opts := range r.GTFSOptions {
+		if err := opts.Validate(); err != nil {
+			return fmt.Errorf("options for %s: %w", url, err)
+		}
+	}
+	return nil
+}
+
+// MotisRelease returns the release tag of the chosen MOTIS asset, e.g. v2.6.1
+// for .../releases/download/v2.6.1/motis-linux-amd64.tar.bz2.
+func (r RequestDownload) MotisRelease() string {
+	parts := strings.Split(r.MotisUrl, "/")
+	for i := 0; i+1 < len(parts); i++ {
+		if parts[i] == "download" && i > 0 && parts[i-1] == "releases" {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
+
+// DatasetOptions returns the per-feed options keyed by the downloaded file name.
+func (r RequestDownload) DatasetOptions() map[string]motisconfigfile.DatasetOptions {
+	result := map[string]motisconfigfile.DatasetOptions{}
+	for url, opts := range r.GTFSOptions {
+		result[FeedFileName(url)] = opts
+	}
+	return result
+}
+
+// FileName returns the base file name of a URL without query, the name
+// downloads are stored under.
+func FileName(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil && u.Path != "" {
+		return path.Base(u.Path)
+	}
+	return path.Base(rawURL)
+}
+
+// FeedFileName returns the name a GTFS feed is stored under in out/. Feeds
+// are stored as *.gtfs.zip, which is how the config generation finds them.
+// Mobility Database serves every feed as .../mdb-<id>/latest.zip, these
+// are named after the directory to keep them apart.
+func FeedFileName(rawURL string) string {
+	name := FileName(rawURL)
+	stem := strings.TrimSuffix(strings.TrimSuffix(name, ".zip"), ".gtfs")
+	if stem == "latest" {
+		if u, err := url.Parse(rawURL); err == nil {
+			if dir := path.Base(path.Dir(u.Path)); dir != "/" && dir != "." {
+				stem = dir
+			}
+		}
+	}
+	return stem + ".gtfs.zip"
 }
 
 // ProgressCallback is a function type called with progress updates.
@@ -51,10 +118,14 @@ func (pw *ProgressWriter) Write(p []byte) (n int, err error) {
 	return n, err
 }
 
-// downloadFileWithProgress downloads a file from the given URL and writes it to outDir
+// DownloadFileWithProgress downloads a file from the given URL and writes it to outDir
 // using the file's base name. It calls progressCallback with progress updates.
-func downloadFileWithProgress(url, outDir string, progressCallback ProgressCallback) error {
-	fileName := extractFileName(url)
+func DownloadFileWithProgress(url, outDir string, progressCallback ProgressCallback) error {
+	return downloadFile(url, outDir, FileName(url), progressCallback)
+}
+
+// downloadFile downloads url to fileName in outDir.
+func downloadFile(url, outDir, fileName string, progressCallback ProgressCallback) error {
 	outPath := filepath.Join(outDir, fileName)
 
 	// Create the output file.
@@ -101,8 +172,8 @@ func downloadFileWithProgress(url, outDir string, progressCallback ProgressCallb
 	return nil
 }
 
-// extractTarBz2 extracts a .tar.bz2 archive (Motis) into outDir.
-func extractTarBz2(filePath, outDir string) error {
+// ExtractTarBz2 extracts a .tar.bz2 archive (Motis) into outDir.
+func ExtractTarBz2(filePath, outDir string) error {
 	// Open the tar.bz2 file.
 	f, err := os.Open(filePath)
 	if err != nil {
@@ -125,6 +196,10 @@ func extractTarBz2(filePath, outDir string) error {
 		}
 
 		target := filepath.Join(outDir, header.Name)
+		root := filepath.Clean(outDir)
+		if target != root && !strings.HasPrefix(target, root+string(filepath.Separator)) {
+			return fmt.Errorf("archive entry %s escapes %s", header.Name, outDir)
+		}
 		switch header.Typeflag {
 		case tar.TypeDir:
 			// Create directory if needed.
@@ -156,7 +231,7 @@ func extractTarBz2(filePath, outDir string) error {
 
 // DownloadAll downloads all files (GTFS, Osm, and Motis) concurrently with a maximum
 // of 5 simultaneous downloads. It calls progressCallback with progress updates for each file.
-// After all downloads complete, it extracts the Motis archive.
+// The Motis archive is left in out/ for versions.Manager to install.
 func DownloadAll(req RequestDownload, progressCallback ProgressCallback) error {
 	outDir := "out"
 	if err := os.MkdirAll(outDir, 0755); err != nil {
@@ -168,12 +243,12 @@ func DownloadAll(req RequestDownload, progressCallback ProgressCallback) error {
 	errorsChan := make(chan error, len(req.GTFSURLs)+2)
 
 	// Helper function to run a single download task.
-	downloadTask := func(url string, taskName string) {
+	downloadTask := func(url, fileName string, taskName string) {
 		defer wg.Done()
 		sem <- struct{}{}
 		defer func() { <-sem }()
 		fmt.Printf("Starting download for %s: %s\n", taskName, url)
-		if err := downloadFileWithProgress(url, outDir, progressCallback); err != nil {
+		if err := downloadFile(url, outDir, fileName, progressCallback); err != nil {
 			errorsChan <- fmt.Errorf("%s download error for %s: %w", taskName, url, err)
 			return
 		}
@@ -183,16 +258,16 @@ func DownloadAll(req RequestDownload, progressCallback ProgressCallback) error {
 	// Download all GTFS URLs.
 	for _, url := range req.GTFSURLs {
 		wg.Add(1)
-		go downloadTask(url, "GTFS")
+		go downloadTask(url, FeedFileName(url), "GTFS")
 	}
 
 	// Download the Osm file.
 	wg.Add(1)
-	go downloadTask(req.OsmURL, "Osm")
+	go downloadTask(req.OsmURL, FileName(req.OsmURL), "Osm")
 
 	// Download the Motis file.
 	wg.Add(1)
-	go downloadTask(req.MotisUrl, "Motis")
+	go downloadTask(req.MotisUrl, FileName(req.MotisUrl), "Motis")
 
 	// Wait for all downloads to finish.
 	wg.Wait()
@@ -204,13 +279,5 @@ func DownloadAll(req RequestDownload, progressCallback ProgressCallback) error {
 		}
 	}
 
-	// After downloading, extract the Motis archive.
-	motisFileName := extractFileName(req.MotisUrl)
-	motisFilePath := filepath.Join(outDir, motisFileName)
-	fmt.Printf("Extracting Motis file: %s\n", motisFilePath)
-	if err := extractTarBz2(motisFilePath, outDir); err != nil {
-		return fmt.Errorf("failed extracting motis file: %w", err)
-	}
-
 	return nil
 }
@@ -0,0 +1,114 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"maxiputz/motisConfigServer/scrapper"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// importFactor is a rough estimate of the disk space MOTIS import needs
+// per byte of OSM input (street graph, tiles and geocoding data).
+const importFactor = 4
+
+// PlannedFile is one file of a download request.
+type PlannedFile struct {
+	Kind         string     `json:"kind"`
+	URL          string     `json:"url"`
+	Name         string     `json:"name"`
+	Size         int64      `json:"size"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+	// SizeKnown is false if neither the catalog nor the server told the size.
+	SizeKnown bool `json:"sizeKnown"`
+}
+
+// Plan summarises what a download request will fetch and store.
+type Plan struct {
+	Files         []PlannedFile `json:"files"`
+	DownloadBytes int64         `json:"downloadBytes"`
+	// DiskBytes estimates the disk space needed for the downloads and the
+	// MOTIS import.
+	DiskBytes int64 `json:"diskBytes"`
+	FreeBytes int64 `json:"freeBytes"`
+	Enough    bool  `json:"enough"`
+	// UnknownSizes counts files without a known size; the totals are lower
+	// bounds if it is not zero.
+	UnknownSizes int `json:"unknownSizes"`
+}
+
+// KnownFileInfo looks up the file info of url in a catalog.
+type KnownFileInfo func(url string) (scrapper.FileInfo, bool)
+
+// PlanDownload determines size and modification time of every file of req,
+// using known first and HEAD requests otherwise, and compares the disk
+// requirement with the free space in outDir.
+func PlanDownload(ctx context.Context, req RequestDownload, outDir string, known KnownFileInfo) (Plan, error) {
+	plan := Plan{Files: []PlannedFile{}}
+
+	add := func(kind, url string) {
+		file := PlannedFile{Kind: kind, URL: url, Name: FileName(url)}
+		if kind == "GTFS" {
+			file.Name = FeedFileName(url)
+		}
+		info, ok := scrapper.FileInfo{}, false
+		if known != nil {
+			info, ok = known(url)
+		}
+		if !ok || info.Size <= 0 {
+			fetched, err := scrapper.FetchFileInfo(ctx, url)
+			if err == nil {
+				info, ok = fetched, true
+			}
+		}
+		if ok && info.Size > 0 {
+			file.Size = info.Size
+			file.SizeKnown = true
+			file.LastModified = info.LastModified
+		} else {
+			plan.UnknownSizes++
+		}
+		plan.Files = append(plan.Files, file)
+		plan.DownloadBytes += file.Size
+		plan.DiskBytes += file.Size
+		if kind == "Osm" {
+			plan.DiskBytes += file.Size * importFactor
+		}
+	}
+
+	for _, url := range req.GTFSURLs {
+		add("GTFS", url)
+	}
+	add("Osm", req.OsmURL)
+	add("Motis", req.MotisUrl)
+
+	free, err := freeBytes(outDir)
+	if err != nil {
+		return plan, fmt.Errorf("failed to read free disk space: %w", err)
+	}
+	plan.FreeBytes = free
+	plan.Enough = free >= plan.DiskBytes
+	return plan, nil
+}
+
+// freeBytes returns the space available to unprivileged users at dir or,
+// if dir does not exist yet, at its closest existing parent.
+func freeBytes(dir string) (int64, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		stat := syscall.Statfs_t{}
+		err := syscall.Statfs(dir, &stat)
+		if err == nil {
+			return int64(stat.Bavail) * int64(stat.Bsize), nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return 0, err
+		}
+		dir = parent
+	}
+}
@@ -3,35 +3,67 @@ package main
 import (
 	"bufio"
 	"context"
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
+	"maxiputz/motisConfigServer/catalog"
+	"maxiputz/motisConfigServer/deploy"
 	"maxiputz/motisConfigServer/download"
 	motisconfigfile "maxiputz/motisConfigServer/motisConfigFile"
+	"maxiputz/motisConfigServer/provider"
+	"maxiputz/motisConfigServer/scheduler"
 	"maxiputz/motisConfigServer/scrapper"
+	"maxiputz/motisConfigServer/search"
+	"maxiputz/motisConfigServer/spatial"
+	"maxiputz/motisConfigServer/suggest"
+	"maxiputz/motisConfigServer/versions"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path"
 	"path/filepath"
-	"runtime"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/compress"
 	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/fiber/v2/middleware/etag"
 	"github.com/gofiber/fiber/v2/middleware/filesystem"
 )
 
 type Response struct {
-	Region   scrapper.Region       `json:"region"`
-	Releases []scrapper.Release    `json:"releases"`
-	GTFSUrl  []scrapper.Transitous `json:"gtfsUrl"`
-	OS       string                `json:"os"`
-	Arch     string                `json:"arch"`
+	// Region is only sent for /init?tree=full, use Regions and
+	// /regions/:id/children otherwise.
+	Region   *scrapper.Region         `json:"region,omitempty"`
+	Regions  []scrapper.RegionSummary `json:"regions"`
+	Releases []scrapper.Release       `json:"releases"`
+	GTFSUrl  []scrapper.Transitous    `json:"gtfsUrl"`
+	OS       string                   `json:"os"`
+	Arch     string                   `json:"arch"`
+	// Sources tells where each catalog came from, its state and fetch time.
+	Sources []catalog.Status `json:"sources"`
+}
+
+// CatalogStatus adds the values changing with every request to the
+// sources of /init: the catalog ages and the GitHub rate limit.
+type CatalogStatus struct {
+	Sources []catalog.Status `json:"sources"`
+	// GitHubRateLimit is the request budget left for release refreshes.
+	GitHubRateLimit *scrapper.RateLimit `json:"githubRateLimit,omitempty"`
 }
 
 type SocketChunk struct {
@@ -46,6 +78,31 @@ type SocketChunkString struct {
 
 var writeMutex sync.Mutex
 
+// callbackMutex guards the callbacks that the websocket handler replaces
+// while scheduled refreshes and downloads call them.
+var callbackMutex sync.Mutex
+
+// configPath is the MOTIS config written by runMotisCondfig.
+const configPath = "out/config.yml"
+
+// profilesDir holds custom config profiles as YAML files.
+const profilesDir = "out/profiles"
+
+// catalogDir holds the fetched Geofabrik, Transitous and MOTIS catalogs.
+const catalogDir = "out/catalog"
+
+// requestPath stores the last accepted download request.
+const requestPath = "out/downloadUrls.json"
+
+// schedulePath overrides the cron expressions of the catalog refreshes.
+const schedulePath = "out/schedule.yml"
+
+// providersPath selects the providers the catalogs are fetched from.
+const providersPath = "out/providers.yml"
+
+// githubTokenPath holds a GitHub token used if GITHUB_TOKEN is not set.
+const githubTokenPath = "out/github.token"
+
 //go:embed "ui/dist/*"
 var folderPath embed.FS
 
@@ -54,44 +111,349 @@ var assatsPath embed.FS
 
 func main() {
 
-	regions, releases, transitous, err := scrapper.GetAllAssetes()
-
-	downLoadCallback := func(name string, prgress string) {}
-	motisImportCallback := func(data string) {}
+	embeddedAssets, err := fs.Sub(assatsPath, "assets")
+	if err != nil {
+		panic(err)
+	}
+	catalogs := catalog.New(embeddedAssets, catalog.NewStore(catalogDir))
 
-	hostOS := runtime.GOOS
-	if hostOS == "darwin" {
-		hostOS = "macos"
+	scrapper.GitHub.CacheDir = filepath.Join(catalogDir, "github")
+	if scrapper.GitHub.Token == "" {
+		if token, err := os.ReadFile(githubTokenPath); err == nil {
+			scrapper.GitHub.Token = strings.TrimSpace(string(token))
+		}
 	}
 
+	providerConfig, err := provider.LoadConfig(providersPath)
 	if err != nil {
-		panic(err)
+		log.Printf("Error loading %s, using the default providers: %v", providersPath, err)
+		providerConfig = provider.DefaultConfig
 	}
+	providers, err := provider.Build(providerConfig)
+	if err != nil {
+		log.Printf("Error in %s: %v", providersPath, err)
+	}
+	catalogs.UseProviders(providers)
+
+	// The region index is rebuilt whenever the Geofabrik catalog changes.
+	var regionIndex atomic.Pointer[spatial.Index]
+	catalogs.OnUpdate(func(source catalog.Source) {
+		if source == catalog.Geofabrik {
+			regionIndex.Store(spatial.Build(catalogs.Region()))
+		}
+	})
+
+	if err := catalogs.LoadOffline(); err != nil {
+		log.Printf("Error loading catalogs: %v", err)
+	}
+	go catalogs.RefreshStale(context.Background())
+
+	catalogChangeCallback := func(changes catalog.ChangeSet) {}
+	catalogs.OnChange(func(changes catalog.ChangeSet) {
+		callbackMutex.Lock()
+		callback := catalogChangeCallback
+		callbackMutex.Unlock()
+		callback(changes)
+	})
+
+	refreshes := startScheduler(catalogs)
+
+	installed := versions.NewManager("out")
+
+	downLoadCallback := func(name string, prgress string) {}
+	notifyDownload := func(name, progress string) {
+		callbackMutex.Lock()
+		callback := downLoadCallback
+		callbackMutex.Unlock()
+		callback(name, progress)
+	}
+	motisImportCallback := func(data string) {}
+	notifyImport := func(data string) {
+		callbackMutex.Lock()
+		callback := motisImportCallback
+		callbackMutex.Unlock()
+		callback(data)
+	}
+	preflightCallback := func(report versions.Report) {}
+	notifyPreflight := func(report versions.Report) {
+		callbackMutex.Lock()
+		callback := preflightCallback
+		callbackMutex.Unlock()
+		callback(report)
+	}
+
+	host := scrapper.CurrentHost()
 
 	app := fiber.New()
 	app.Use(cors.New())
-
-	app.Use("/", filesystem.New(filesystem.Config{
-		Root:       http.FS(folderPath),
-		PathPrefix: "ui/dist", // Path prefix inside the embedded FS
-		Browse:     true,      // Allow directory browsing (optional)
-	}))
+	app.Use(compress.New())
+	app.Use(etag.New())
 
 	// Start the server
 
 	app.Get("/init", func(c *fiber.Ctx) error {
 
-		return c.JSON(Response{
-			Region:   regions,
-			Releases: releases,
-			GTFSUrl:  transitous,
-			OS:       hostOS,
-			Arch:     runtime.GOARCH,
+		tree := catalogs.Region()
+		response := Response{
+			Regions:  regionChildren(tree),
+			Releases: catalogs.Releases(),
+			GTFSUrl:  catalogs.Transitous(),
+			OS:       host.OS,
+			Arch:     host.Arch,
+			Sources:  catalogs.SourceStatuses(),
+		}
+		if c.Query("tree") == "full" {
+			full := tree.WithoutGeometry()
+			response.Region = &full
+		}
+		if c.Query("releases") != "all" {
+			response.Releases = scrapper.FilterReleases(response.Releases, host.OS, host.Arch)
+		}
+		return c.JSON(response)
+	})
+
+	app.Get("/regions/:id/children", func(c *fiber.Ctx) error {
+		id, err := url.PathUnescape(c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		tree := catalogs.Region()
+		region := tree
+		if id != "root" {
+			found, ok := tree.Find(func(r scrapper.Region) bool { return r.Key() == id && r.OsmData != "" })
+			if !ok {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "unknown region " + id})
+			}
+			region = found
+		}
+
+		children := regionChildren(region)
+		offset := max(c.QueryInt("offset"), 0)
+		limit := c.QueryInt("limit", 100)
+		if limit <= 0 || limit > 500 {
+			limit = 500
+		}
+		page := children[min(offset, len(children)):min(offset+limit, len(children))]
+		return c.JSON(fiber.Map{
+			"region":   region.Summary(),
+			"total":    len(children),
+			"offset":   offset,
+			"limit":    limit,
+			"children": page,
+		})
+	})
+
+	app.Get("/releases", func(c *fiber.Ctx) error {
+		releases := catalogs.Releases()
+		if !c.QueryBool("prerelease", true) {
+			releases = scrapper.StableReleases(releases)
+		}
+		if c.Query("all") != "true" {
+			releases = scrapper.FilterReleases(releases, c.Query("os", host.OS), c.Query("arch", host.Arch))
+		}
+		return c.JSON(releases)
+	})
+
+	app.Get("/releases/best", func(c *fiber.Ctx) error {
+		target := host
+		target.OS = c.Query("os", host.OS)
+		target.Arch = c.Query("arch", host.Arch)
+		if target.OS != host.OS {
+			target.Libc = ""
+		}
+		target.Libc = c.Query("libc", target.Libc)
+		target.AVX2 = c.QueryBool("avx2", host.AVX2)
+		release, asset, ok := scrapper.BestAsset(catalogs.Releases(), target)
+		if !ok {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("no stable release for %s/%s", target.OS, target.Arch)})
+		}
+		return c.JSON(fiber.Map{
+			"host":    target,
+			"tagName": release.TagName,
+			"name":    release.Name,
+			"asset":   asset,
+		})
+	})
+
+	// /releases/changelog lists the releases between the installed release
+	// (or ?from=) and the latest stable one (or ?to=) with their notes.
+	app.Get("/releases/changelog", func(c *fiber.Ctx) error {
+		releases := catalogs.Releases()
+		from := c.Query("from")
+		if from == "" {
+			from, _ = installed.Current()
+		}
+		to := c.Query("to")
+		if to == "" {
+			stable := scrapper.StableReleases(releases)
+			if len(stable) == 0 {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "no stable release known"})
+			}
+			to = stable[0].TagName
+		}
+		return c.JSON(fiber.Map{
+			"from":     from,
+			"to":       to,
+			"releases": scrapper.Changelog(releases, from, to),
+		})
+	})
+
+	app.Get("/versions", func(c *fiber.Ctx) error {
+		list, err := installed.List()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		current, _ := installed.Current()
+		return c.JSON(fiber.Map{"current": current, "versions": list})
+	})
+
+	// /versions/install downloads a release into its own directory. Without
+	// a url the asset best matching the host is taken.
+	app.Post("/versions/install", func(c *fiber.Ctx) error {
+		body := struct {
+			Tag      string `json:"tag"`
+			URL      string `json:"url"`
+			Activate bool   `json:"activate"`
+		}{}
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err := versions.ValidateTag(body.Tag); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		if body.URL == "" {
+			asset, ok := scrapper.Asset{}, false
+			for _, release := range catalogs.Releases() {
+				if release.TagName == body.Tag {
+					asset, ok = scrapper.BestReleaseAsset(release, host)
+					break
+				}
+			}
+			if !ok {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("no asset of %s for %s/%s", body.Tag, host.OS, host.Arch)})
+			}
+			body.URL = asset.Browser_download_url
+		}
+
+		go func() {
+			version, err := installed.Install(body.Tag, body.URL, func(fileName string, downloaded, total int64) {
+				notifyDownload(fileName, strconv.FormatFloat(float64(downloaded)/float64(total)*100, 'f', 2, 64))
+			})
+			if err != nil {
+				fmt.Printf("Error installing MOTIS %s: %v\n", body.Tag, err)
+				return
+			}
+			if body.Activate {
+				if err := installed.Activate(version.Tag); err != nil {
+					fmt.Printf("Error activating MOTIS %s: %v\n", version.Tag, err)
+				}
+			}
+		}()
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"tag": body.Tag, "url": body.URL})
+	})
+
+	app.Post("/versions/:tag/activate", func(c *fiber.Ctx) error {
+		tag, _ := url.PathUnescape(c.Params("tag"))
+		if err := installed.Activate(tag); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(fiber.Map{"current": tag})
+	})
+
+	app.Get("/versions/:tag/preflight", func(c *fiber.Ctx) error {
+		tag, _ := url.PathUnescape(c.Params("tag"))
+		report, err := installed.Preflight(tag)
+		if err != nil && report.Binary == "" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			fmt.Printf("Error recording preflight of %s: %v\n", tag, err)
+		}
+		return c.JSON(report)
+	})
+
+	app.Delete("/versions/:tag", func(c *fiber.Ctx) error {
+		tag, _ := url.PathUnescape(c.Params("tag"))
+		if err := installed.Remove(tag); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	app.Get("/scheduler/runs", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{
+			"jobs": refreshes.Jobs(),
+			"runs": refreshes.Runs(c.Query("job")),
+		})
+	})
+
+	// /providers lists the registered provider types and the configured
+	// providers of each catalog.
+	app.Get("/providers", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{
+			"types":  provider.Types(),
+			"config": providerConfig,
+		})
+	})
+
+	// /catalog/changes lists what was added, removed or renamed by past
+	// refreshes, optionally for one ?source= and ?since= an RFC 3339 time.
+	app.Get("/catalog/changes", func(c *fiber.Ctx) error {
+		var source catalog.Source
+		if name := c.Query("source"); name != "" {
+			parsed, err := catalog.ParseSource(name)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			}
+			source = parsed
+		}
+		var since time.Time
+		if value := c.Query("since"); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "since must be an RFC 3339 time"})
+			}
+			since = parsed
+		}
+		changes, err := catalogs.Changes(source, since)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(changes)
+	})
+
+	app.Get("/catalog/status", func(c *fiber.Ctx) error {
+		return c.JSON(CatalogStatus{
+			Sources:         catalogs.Statuses(),
+			GitHubRateLimit: scrapper.GitHub.RateLimit(),
+		})
+	})
+
+	app.Post("/catalog/refresh", func(c *fiber.Ctx) error {
+		sources := catalog.Sources
+		if name := c.Query("source"); name != "" {
+			source, err := catalog.ParseSource(name)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			}
+			sources = []catalog.Source{source}
+		}
+
+		started := []catalog.Source{}
+		for _, source := range sources {
+			if catalogs.RefreshInBackground(source) {
+				started = append(started, source)
+			}
+		}
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+			"started": started,
+			"sources": catalogs.Statuses(),
 		})
 	})
 
 	app.Get("/ws/", websocket.New(func(c *websocket.Conn) {
 
+		callbackMutex.Lock()
 		downLoadCallback = func(name, prgress string) {
 			writeMutex.Lock()
 			defer writeMutex.Unlock()
@@ -107,9 +469,28 @@ func main() {
 				Data: data,
 			})
 		}
+		catalogChangeCallback = func(changes catalog.ChangeSet) {
+			data, _ := json.Marshal(changes)
+			writeMutex.Lock()
+			defer writeMutex.Unlock()
+			c.WriteJSON(SocketChunkString{
+				Name: "catalogChanged",
+				Data: string(data),
+			})
+		}
+		preflightCallback = func(report versions.Report) {
+			data, _ := json.Marshal(report)
+			writeMutex.Lock()
+			defer writeMutex.Unlock()
+			c.WriteJSON(SocketChunkString{
+				Name: "preflight",
+				Data: string(data),
+			})
+		}
+		callbackMutex.Unlock()
 
 		for {
-			if _, _, err = c.ReadMessage(); err != nil {
+			if _, _, err := c.ReadMessage(); err != nil {
 				log.Println("read:", err)
 				break
 			}
@@ -122,6 +503,22 @@ func main() {
 		if err := c.BodyParser(&reqData); err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 		}
+		if _, err := configOptions(reqData, catalogs.Transitous()); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		if c.Query("force") != "true" {
+			plan, err := download.PlanDownload(c.Context(), reqData, "out", catalogFileInfo(catalogs))
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+			}
+			if !plan.Enough {
+				return c.Status(fiber.StatusInsufficientStorage).JSON(fiber.Map{
+					"error": "not enough disk space, retry with ?force=true to download anyway",
+					"plan":  plan,
+				})
+			}
+		}
 
 		fmt.Printf("reqData: %+v\n", reqData)
 
@@ -134,13 +531,28 @@ func main() {
 				fmt.Printf("(downloaded / total): %v\n", (float64(downloaded) / float64(total) * 100))
 
 				progress := strconv.FormatFloat((float64(downloaded)/float64(total))*100, 'f', 2, 64)
-				downLoadCallback(fileName, progress)
+				notifyDownload(fileName, progress)
 			})
 
+			if err := installDownloadedRelease(installed, reqData); err != nil {
+				fmt.Printf("Error installing MOTIS %s: %v\n", reqData.MotisRelease(), err)
+			}
+			// The failed report reaches the client as "preflight" message;
+			// configuring a binary that cannot run is pointless.
+			report := preflightMotis(installed)
+			notifyPreflight(report)
+			if !report.OK() {
+				fmt.Printf("MOTIS binary cannot run on this host, download job aborted: %s\n", strings.Join(report.Problems, "; "))
+				return
+			}
+
 			feeds, _ := findGtfsInOut()
 			osmFile, _ := findOsmInOut()
 			fmt.Printf("\"config is stared\": %v\n", "config is stared")
-			runMotisCondfig(feeds, osmFile)
+			runMotisCondfig(feeds, osmFile, reqData, catalogs.Transitous())
+			if _, err := generateDeployment(reqData, "", ""); err != nil {
+				fmt.Printf("Error writing deployment files: %v\n", err)
+			}
 			fmt.Printf("config is writte you can run on your host pc ./motis import \n")
 			fmt.Printf("after the import is run through you can run ./motis serve \n")
 
@@ -151,23 +563,202 @@ func main() {
 				panic(1)
 			}
 
-			os.WriteFile("./out/downloadUrls.json", reqestDataJson, 0664)
+			os.WriteFile(requestPath, reqestDataJson, 0664)
 
 			os.Exit(0)
-			runMotisImportCallback(motisImportCallback)
+			runMotisImportCallback(notifyImport)
 
 		}()
 		return c.SendString("sending data")
 	})
 
-	app.Get("/import", func(c *fiber.Ctx) error {
-		DownLoadWget("https://github.com/motis-project/motis/releases/download/v2.0.43/motis-macos-arm64.tar.bz2", func(data string) {
-			fmt.Printf("data: %v\n", data)
+	app.Get("/regions/lookup", func(c *fiber.Ctx) error {
+		lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+		lon, errLon := strconv.ParseFloat(c.Query("lon"), 64)
+		if errLat != nil || errLon != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "lat and lon must be valid coordinates"})
+		}
+		index := regionIndex.Load()
+		if index == nil || index.Len() == 0 {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "no region geometries loaded, refresh the geofabrik catalog"})
+		}
+		return c.JSON(index.Lookup(lat, lon))
+	})
+
+	app.Get("/regions/cover", func(c *fiber.Ctx) error {
+		bbox, err := spatial.ParseBBox(c.Query("bbox"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		index := regionIndex.Load()
+		if index == nil || index.Len() == 0 {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "no region geometries loaded, refresh the geofabrik catalog"})
+		}
+		return c.JSON(index.Cover(bbox))
+	})
+
+	app.Post("/regions/suggest", func(c *fiber.Ctx) error {
+		body := struct {
+			GTFSURLs []string `json:"gtfsUrls"`
+		}{}
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		if len(body.GTFSURLs) == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "no GTFS feeds selected"})
+		}
+		return c.JSON(suggest.Suggest(catalogs.Region(), regionIndex.Load(), suggestFeeds(body.GTFSURLs, catalogs.Transitous())))
+	})
+
+	app.Get("/regions/search", func(c *fiber.Ctx) error {
+		page := search.Search(catalogs.Region().Flatten(), c.Query("q"), func(r scrapper.RegionSummary) []search.Field {
+			fields := []search.Field{
+				{Text: r.RegionName, Weight: 1},
+				{Text: r.Path, Weight: 0.7},
+				{Text: r.ID, Weight: 0.8},
+			}
+			for _, iso := range append(r.ISO3166_1, r.ISO3166_2...) {
+				fields = append(fields, search.Field{Text: iso, Weight: 0.9})
+			}
+			return fields
+		}, c.QueryInt("offset"), c.QueryInt("limit"))
+		return c.JSON(page)
+	})
+
+	app.Get("/feeds/search", func(c *fiber.Ctx) error {
+		page := search.Search(catalogs.Transitous(), c.Query("q"), func(t scrapper.Transitous) []search.Field {
+			return []search.Field{
+				{Text: strings.TrimSuffix(t.Name, ".gtfs.zip"), Weight: 1},
+				{Text: t.Source, Weight: 0.9},
+				{Text: t.Provider, Weight: 0.9},
+				{Text: t.Municipality, Weight: 0.8},
+				{Text: t.Subdivision, Weight: 0.8},
+				{Text: t.SubdivisionName, Weight: 0.8},
+				{Text: t.Country, Weight: 0.7},
+				{Text: t.Url, Weight: 0.5},
+			}
+		}, c.QueryInt("offset"), c.QueryInt("limit"))
+		return c.JSON(page)
+	})
+
+	app.Post("/download/plan", func(c *fiber.Ctx) error {
+		reqData := download.RequestDownload{}
+		if err := c.BodyParser(&reqData); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		plan, err := download.PlanDownload(c.Context(), reqData, "out", catalogFileInfo(catalogs))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(plan)
+	})
+
+	app.Get("/profiles", func(c *fiber.Ctx) error {
+		profiles, err := motisconfigfile.ListProfiles(profilesDir)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(profiles)
+	})
+
+	app.Post("/config/preview", func(c *fiber.Ctx) error {
+		reqData := download.RequestDownload{}
+		if err := c.BodyParser(&reqData); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		opts, err := configOptions(reqData, catalogs.Transitous())
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		feeds := []string{}
+		for _, url := range reqData.GTFSURLs {
+			feeds = append(feeds, download.FeedFileName(url))
+		}
+
+		config, err := motisconfigfile.RenderMotisConfig(path.Base(reqData.OsmURL), feeds, opts)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(fiber.Map{
+			"profile": opts.Profile.Name,
+			"config":  config,
 		})
-		runMotisImportCallback(motisImportCallback)
+	})
+
+	app.Get("/config/diff", func(c *fiber.Ctx) error {
+		feeds, _ := findGtfsInOut()
+		osmFile, err := findOsmInOut()
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		// Regenerate with the options of the last download; the profile can be overridden.
+		lastReq, _ := loadLastRequest()
+		if profileName := c.Query("profile"); profileName != "" {
+			lastReq.Profile = profileName
+		}
+		opts, err := configOptions(lastReq, catalogs.Transitous())
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		current, err := os.ReadFile(configPath)
+		if err != nil && !os.IsNotExist(err) {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		exists := err == nil
+
+		generated, err := motisconfigfile.RenderMotisConfig(osmFile, feeds, opts)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		diff := motisconfigfile.UnifiedDiff(configPath, configPath+" (generated)", string(current), generated)
+
+		return c.JSON(fiber.Map{
+			"path":    configPath,
+			"profile": opts.Profile.Name,
+			"exists":  exists,
+			"changed": diff != "",
+			"diff":    diff,
+		})
+	})
+
+	app.Post("/deploy/generate", func(c *fiber.Ctx) error {
+		reqData, err := loadLastRequest()
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "no download request found: " + err.Error()})
+		}
+		written, err := generateDeployment(reqData, c.Query("user"), c.Query("image"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(written)
+	})
+
+	app.Get("/import", func(c *fiber.Ctx) error {
+		report := preflightMotis(installed)
+		notifyPreflight(report)
+		if !report.OK() {
+			return c.Status(fiber.StatusConflict).JSON(report)
+		}
+		go func() {
+			if err := runMotisImportCallback(notifyImport); err != nil {
+				fmt.Printf("Error running import: %v\n", err)
+			}
+		}()
 		return c.SendString("import is started")
 	})
 
+	// Registered after the API routes, so that a missing file does not turn
+	// their responses into 404s.
+	app.Use("/", filesystem.New(filesystem.Config{
+		Root:       http.FS(folderPath),
+		PathPrefix: "ui/dist", // Path prefix inside the embedded FS
+		Browse:     true,      // Allow directory browsing (optional)
+	}))
+
 	fmt.Printf("\"👉 Open http://localhost:3001 in your browser to finish setup!\": %v\n", "👉 Open http://localhost:3001 in your browser to finish setup!")
 	app.Listen(":3001")
 	fmt.Printf("\"👉 Open http://localhost:3001 in your browser to finish setup!\": %v\n", "👉 Open http://localhost:3001 in your browser to finish setup!")
@@ -227,26 +818,219 @@ func findOsmInOut() (string, error) {
 	}
 	return result, nil
 }
-func runMotisCondfig(feeds []string, osmFile string) {
 
-	motisconfigfile.GenerateMotisConfig(osmFile, feeds, "out/")
+// regionChildren returns the summaries of the direct children of region.
+func regionChildren(region scrapper.Region) []scrapper.RegionSummary {
+	children := []scrapper.RegionSummary{}
+	for _, child := range region.Children {
+		children = append(children, child.Summary())
+	}
+	return children
+}
+
+// catalogFileInfo looks up file sizes of OSM extracts in the Geofabrik catalog.
+func catalogFileInfo(catalogs *catalog.Catalog) download.KnownFileInfo {
+	return func(url string) (scrapper.FileInfo, bool) {
+		region, ok := catalogs.Region().FindByURL(url)
+		if !ok || region.Size == 0 {
+			return scrapper.FileInfo{}, false
+		}
+		return scrapper.FileInfo{Size: region.Size, LastModified: region.LastModified}, true
+	}
+}
 
-	return
-	args := append([]string{"config", osmFile}, feeds...)
-	fmt.Printf("\"motis\": %v\n", "motis")
-	fmt.Printf("args: %v\n", args)
-	cmd := exec.Command("./motis", args...)
-	cmd.Dir = "out" // Set the working directory to "out"
-	output, err := cmd.CombinedOutput()
+// loadLastRequest reads the download request persisted by /startDownload.
+func loadLastRequest() (download.RequestDownload, error) {
+	reqData := download.RequestDownload{}
+	data, err := os.ReadFile(requestPath)
+	if err != nil {
+		return reqData, err
+	}
+	err = json.Unmarshal(data, &reqData)
+	return reqData, err
+}
+
+// suggestFeeds looks up the catalog entries of the selected feeds and reads
+// the stops of those already downloaded to out/.
+func suggestFeeds(gtfsURLs []string, catalogFeeds []scrapper.Transitous) []suggest.Feed {
+	feeds := []suggest.Feed{}
+	for _, feedURL := range gtfsURLs {
+		name := download.FeedFileName(feedURL)
+		feed := suggest.Feed{Transitous: scrapper.Transitous{Name: name, Url: feedURL}}
+		if i := slices.IndexFunc(catalogFeeds, func(t scrapper.Transitous) bool { return t.Url == feedURL }); i >= 0 {
+			feed.Transitous = catalogFeeds[i]
+		}
+		if feed.Country == "" && feed.MdbID == "" {
+			// Derive the country from the file name prefix.
+			feed.Transitous = scrapper.EnrichTransitous([]scrapper.Transitous{feed.Transitous}, nil)[0]
+		}
+
+		if bbox, err := suggest.StopsBBox(filepath.Join("out", name)); err == nil {
+			feed.Stops = &bbox
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			fmt.Printf("Error reading stops of %s: %v\n", name, err)
+		}
+		feeds = append(feeds, feed)
+	}
+	return feeds
+}
+
+// configOptions resolves the config generation options of a download
+// request, taking GTFS-RT endpoints from the feed catalog if requested.
+func configOptions(reqData download.RequestDownload, catalogFeeds []scrapper.Transitous) (motisconfigfile.Options, error) {
+	if err := reqData.Validate(); err != nil {
+		return motisconfigfile.Options{}, err
+	}
+	profile, err := motisconfigfile.GetProfile(profilesDir, reqData.Profile)
+	if err != nil {
+		return motisconfigfile.Options{}, err
+	}
+
+	datasets := reqData.DatasetOptions()
+	if reqData.IncludeRealtime {
+		for _, feed := range catalogFeeds {
+			if len(feed.RealtimeURLs) == 0 || !slices.Contains(reqData.GTFSURLs, feed.Url) {
+				continue
+			}
+			name := download.FeedFileName(feed.Url)
+			opts := datasets[name]
+			// Endpoints given in the request take precedence.
+			if len(opts.RealtimeURLs) == 0 {
+				opts.RealtimeURLs = feed.RealtimeURLs
+				datasets[name] = opts
+			}
+		}
+	}
+
+	return motisconfigfile.Options{
+		Profile:  profile,
+		Datasets: datasets,
+		Server:   reqData.Server,
+	}, nil
+}
+
+func runMotisCondfig(feeds []string, osmFile string, reqData download.RequestDownload, catalogFeeds []scrapper.Transitous) {
+	opts, err := configOptions(reqData, catalogFeeds)
 	if err != nil {
-		fmt.Printf("Error running './motis config': %v\nOutput: %s\n", err, output)
+		fmt.Printf("Error loading config options: %v\n", err)
+		return
+	}
+	if err := motisconfigfile.GenerateMotisConfig(osmFile, feeds, "out/", opts); err != nil {
+		fmt.Printf("Error writing config: %v\n", err)
 		return
 	}
-	fmt.Printf("Successfully wrote config:\n%s\n", output)
+	fmt.Printf("Successfully wrote config: %s\n", configPath)
+}
+
+// hostDirEnv names the variable holding out/ as seen on the docker host,
+// set by docker-compose.yml.
+const hostDirEnv = "MOTIS_HOST_DIR"
+
+// generateDeployment writes the scripts, systemd unit and compose file for
+// the server options of a download request into out/.
+func generateDeployment(reqData download.RequestDownload, user, image string) ([]deploy.Artifact, error) {
+	// Pin the artifacts to the installed version of the requested release,
+	// they run the active version otherwise.
+	release, binary := reqData.MotisRelease(), ""
+	installed := versions.NewManager("out")
+	if release != "" {
+		if _, err := installed.Get(release); err == nil {
+			binary, _ = filepath.Rel(installed.WorkDir, installed.Executable(release))
+		}
+	}
+	return deploy.Generate(deploy.Params{
+		WorkDir: "out",
+		HostDir: os.Getenv(hostDirEnv),
+		Release: release,
+		Binary:  binary,
+		Server:  reqData.Server,
+		User:    user,
+		Image:   image,
+	})
+}
+
+// defaultSchedule refreshes the catalogs in the night and every six hours;
+// out/schedule.yml overrides it per source.
+var defaultSchedule = map[string]string{
+	string(catalog.Geofabrik):  "0 3 * * *",
+	string(catalog.Transitous): "15 */6 * * *",
+	string(catalog.Motis):      "45 */6 * * *",
+}
+
+// startScheduler refreshes every catalog on its configured schedule. Invalid
+// entries of the configuration are reported and fall back to the default.
+func startScheduler(catalogs *catalog.Catalog) *scheduler.Scheduler {
+	refreshes := scheduler.New()
+	config, err := scheduler.LoadConfig(schedulePath, defaultSchedule)
+	if err != nil {
+		log.Printf("Error loading %s, using the default schedule: %v", schedulePath, err)
+		config = defaultSchedule
+	}
+	for _, source := range catalog.Sources {
+		refresh := func(ctx context.Context) error {
+			if !catalogs.Online(source) {
+				return fmt.Errorf("%s is offline", source)
+			}
+			return catalogs.Refresh(ctx, source)
+		}
+		expr := config[string(source)]
+		if expr == "" || expr == "off" {
+			continue
+		}
+		if err := refreshes.Add(string(source), expr, refresh); err != nil {
+			log.Printf("Error scheduling %s, using the default schedule: %v", source, err)
+			refreshes.Add(string(source), defaultSchedule[string(source)], refresh)
+		}
+	}
+	go refreshes.Start(context.Background())
+	return refreshes
+}
+
+// installDownloadedRelease installs the MOTIS archive DownloadAll left in
+// out/ as its release, or as custom-<hash> without one, and activates it.
+func installDownloadedRelease(installed *versions.Manager, reqData download.RequestDownload) error {
+	archive := filepath.Join("out", download.FileName(reqData.MotisUrl))
+	tag := reqData.MotisRelease()
+	if tag == "" {
+		// A custom motisUrl names no release, it is installed under a tag
+		// derived from the URL so reinstalling it replaces the version.
+		sum := sha256.Sum256([]byte(reqData.MotisUrl))
+		tag = "custom-" + hex.EncodeToString(sum[:4])
+	}
+	if _, err := installed.InstallArchive(tag, archive, reqData.MotisUrl); err != nil {
+		return err
+	}
+	return installed.Activate(tag)
+}
+
+// preflightMotis checks the binary import and serve will run and records
+// the result for managed versions.
+func preflightMotis(installed *versions.Manager) versions.Report {
+	current, err := installed.Current()
+	if err != nil || current == "" {
+		return versions.Preflight(filepath.Join("out", "motis"))
+	}
+	report, err := installed.Preflight(current)
+	if err != nil {
+		fmt.Printf("Error recording preflight of %s: %v\n", current, err)
+	}
+	return report
+}
+
+// motisBinary returns the motis binary of the active version, or ./motis
+// in out/ for workspaces set up before versions were managed.
+func motisBinary() string {
+	installed := versions.NewManager("out")
+	if current, err := installed.Current(); err == nil && current != "" {
+		if binary, err := filepath.Abs(installed.Binary()); err == nil {
+			return binary
+		}
+	}
+	return "./motis"
 }
 
 func runMotisImport() error {
-	cmd := exec.Command("./motis", "import")
+	cmd := exec.Command(motisBinary(), "import")
 	cmd.Dir = "out" // Set the working directory to "out"
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -263,7 +1047,7 @@ func runMotisImport() error {
 }
 
 func runMotisImportCallback(fn func(data string)) error {
-	cmd := exec.Command("./motis", "import")
+	cmd := exec.Command(motisBinary(), "import")
 	cmd.Dir = "out" // Set the working directory to "out"
 
 	fmt.Println("motis callback fun: starting command")
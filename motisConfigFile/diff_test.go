@@ -0,0 +1,48 @@
+package motisconfigfile
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func numberedLines(from, to int) []string {
+	lines := []string{}
+	for i := from; i <= to; i++ {
+		lines = append(lines, fmt.Sprintf("l%d", i))
+	}
+	return lines
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	twelve := strings.Join(numberedLines(1, 12), "\n") + "\n"
+	changedEnds := strings.Join(append(append([]string{"X"}, numberedLines(2, 11)...), "Y"), "\n") + "\n"
+
+	tests := []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{"equal", "a\nb\n", "a\nb\n", ""},
+		{"change", "a\nb\nc\n", "a\nx\nc\n", "@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n"},
+		{"create", "", "a\nb\n", "@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{"delete", "a\nb\n", "", "@@ -1,2 +0,0 @@\n-a\n-b\n"},
+		{"append", "a\nb\n", "a\nb\nc\n", "@@ -1,2 +1,3 @@\n a\n b\n+c\n"},
+		{"nearby changes share a hunk", "a\nb\nc\nd\ne\n", "a\nB\nc\nD\ne\n",
+			"@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n-d\n+D\n e\n"},
+		{"distant changes get own hunks", twelve, changedEnds,
+			"@@ -1,4 +1,4 @@\n-l1\n+X\n l2\n l3\n l4\n" +
+				"@@ -9,4 +9,4 @@\n l9\n l10\n l11\n-l12\n+Y\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.want
+			if want != "" {
+				want = "--- old\n+++ new\n" + want
+			}
+			if got := UnifiedDiff("old", "new", tt.old, tt.new); got != want {
+				t.Errorf("UnifiedDiff() =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
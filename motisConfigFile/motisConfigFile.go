@@ -1,64 +1,256 @@
 package motisconfigfile
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+
+	"gopkg.in/yaml.v3"
 )
 
+// Config mirrors the parts of the MOTIS config.yml that are generated.
+type Config struct {
+	Server           *Server    `yaml:"server,omitempty"`
+	Osm              string     `yaml:"osm"`
+	Tiles            *Tiles     `yaml:"tiles,omitempty"`
+	Timetable        *Timetable `yaml:"timetable,omitempty"`
+	StreetRouting    bool       `yaml:"street_routing"`
+	OsrFootpath      bool       `yaml:"osr_footpath"`
+	Geocoding        bool       `yaml:"geocoding"`
+	ReverseGeocoding bool       `yaml:"reverse_geocoding"`
+}
+
+type Server struct {
+	Host      string `yaml:"host"`
+	Port      int    `yaml:"port"`
+	WebFolder string `yaml:"web_folder"`
+	NThreads  int    `yaml:"n_threads,omitempty"`
+}
+
+type Tiles struct {
+	Profile        string `yaml:"profile"`
+	DbSize         int64  `yaml:"db_size"`
+	FlushThreshold int    `yaml:"flush_threshold"`
+}
+
+type Timetable struct {
+	FirstDay               string             `yaml:"first_day"`
+	NumDays                int                `yaml:"num_days"`
+	Railviz                bool               `yaml:"railviz"`
+	WithShapes             bool               `yaml:"with_shapes"`
+	AdjustFootpaths        bool               `yaml:"adjust_footpaths"`
+	MergeDupesIntraSrc     bool               `yaml:"merge_dupes_intra_src"`
+	MergeDupesInterSrc     bool               `yaml:"merge_dupes_inter_src"`
+	LinkStopDistance       int                `yaml:"link_stop_distance"`
+	UpdateInterval         int                `yaml:"update_interval"`
+	HttpTimeout            int                `yaml:"http_timeout"`
+	IncrementalRtUpdate    bool               `yaml:"incremental_rt_update"`
+	UseOsmStopCoordinates  bool               `yaml:"use_osm_stop_coordinates"`
+	ExtendMissingFootpaths bool               `yaml:"extend_missing_footpaths"`
+	MaxFootpathLength      int                `yaml:"max_footpath_length"`
+	MaxMatchingDistance    int                `yaml:"max_matching_distance"`
+	Datasets               map[string]Dataset `yaml:"datasets"`
+}
+
+type Dataset struct {
+	Path                string            `yaml:"path"`
+	DefaultBikesAllowed bool              `yaml:"default_bikes_allowed"`
+	DefaultCarsAllowed  bool              `yaml:"default_cars_allowed,omitempty"`
+	ExtendCalendar      bool              `yaml:"extend_calendar,omitempty"`
+	Clasz               map[string]string `yaml:"clasz,omitempty"`
+	Script              string            `yaml:"script,omitempty"`
+	Rt                  []RtFeed          `yaml:"rt,omitempty"`
+}
+
+type RtFeed struct {
+	Url string `yaml:"url"`
+}
+
+// DatasetOptions are the per-feed settings of a download request.
+type DatasetOptions struct {
+	BikesAllowed   bool `json:"bikesAllowed"`
+	CarsAllowed    bool `json:"carsAllowed"`
+	ExtendCalendar bool `json:"extendCalendar"`
+	// Clasz maps a GTFS route_type to the MOTIS clasz, e.g. "3": "COACH".
+	Clasz map[string]string `json:"clasz,omitempty"`
+	// Script is the path of a Lua script MOTIS runs to preprocess the feed.
+	Script string `json:"script,omitempty"`
+	// RealtimeURLs are GTFS-RT endpoints updating the feed.
+	RealtimeURLs []string `json:"realtimeUrls,omitempty"`
+}
+
+// Validate checks that the clasz overrides are keyed by numeric route types.
+func (o DatasetOptions) Validate() error {
+	for routeType, clasz := range o.Clasz {
+		if _, err := strconv.Atoi(routeType); err != nil {
+			return fmt.Errorf("clasz override %q: route_type must be a number", routeType)
+		}
+		if clasz == "" {
+			return fmt.Errorf("clasz override %q: clasz must not be empty", routeType)
+		}
+	}
+	return nil
+}
+
+// ServerOptions are the MOTIS server settings of a download request.
+// Zero values are replaced by the defaults of WithDefaults.
+type ServerOptions struct {
+	Host      string `json:"host"`
+	Port      int    `json:"port"`
+	WebFolder string `json:"webFolder"`
+	// NThreads is the number of server threads, 0 lets MOTIS decide.
+	NThreads int `json:"nThreads"`
+}
+
+// WithDefaults fills unset fields with the MOTIS defaults.
+func (o ServerOptions) WithDefaults() ServerOptions {
+	if o.Host == "" {
+		o.Host = "0.0.0.0"
+	}
+	if o.Port == 0 {
+		o.Port = 8080
+	}
+	if o.WebFolder == "" {
+		o.WebFolder = "ui"
+	}
+	return o
+}
+
+// Validate checks the port and thread count.
+func (o ServerOptions) Validate() error {
+	if o.Port < 0 || o.Port > 65535 {
+		return fmt.Errorf("server port %d out of range", o.Port)
+	}
+	if o.NThreads < 0 {
+		return fmt.Errorf("server thread count %d must not be negative", o.NThreads)
+	}
+	return nil
+}
+
+// Options control what GenerateMotisConfig emits besides the input files.
+type Options struct {
+	Profile Profile
+	// Datasets holds per-feed options keyed by the GTFS file name.
+	Datasets map[string]DatasetOptions
+	Server   ServerOptions
+}
+
+// GenerateConfigCommand writes runMotisConifg.sh, which lets MOTIS itself
+// generate a config for the same input files.
 func GenerateConfigCommand(osmPath string, gtfsFiles []string, outputPath string) {
-	commandStr := "./motis config " + osmPath + strings.Join(gtfsFiles, " ")
+	args := append([]string{"./motis", "config", osmPath}, gtfsFiles...)
+	commandStr := "#!/bin/sh\ncd \"$(dirname \"$0\")\"\n" + strings.Join(args, " ") + "\n"
 
-	os.WriteFile(outputPath+"runMotisConifg.sh", []byte(commandStr), 0664)
+	os.WriteFile(outputPath+"runMotisConifg.sh", []byte(commandStr), 0755)
 }
 
-func GenerateMotisConfig(osmPath string, gtfsFiles []string, outputPath string) error {
+// GenerateServerEnv writes server.env with the listen address of the MOTIS
+// server, so docker-compose can publish the same port:
+//
+//	docker-compose --env-file out/server.env up server
+func GenerateServerEnv(server ServerOptions, outputPath string) error {
+	server = server.WithDefaults()
+	env := fmt.Sprintf("MOTIS_HOST=%s\nMOTIS_PORT=%d\n", server.Host, server.Port)
+	return os.WriteFile(outputPath+"server.env", []byte(env), 0664)
+}
 
-	GenerateConfigCommand(osmPath, gtfsFiles, outputPath)
+// GenerateMotisConfig writes the MOTIS config.yml for the given OSM file,
+// GTFS feeds and options into outputPath.
+func GenerateMotisConfig(osmPath string, gtfsFiles []string, outputPath string, opts Options) error {
 
-	config := strings.Builder{}
-
-	// Base OSM and tile config
-	config.WriteString(fmt.Sprintf(`osm: %s
-tiles:
-  profile: tiles-profiles/full.lua
-  db_size: 274877906944
-  flush_threshold: 100000
-timetable:
-  first_day: TODAY
-  num_days: 365
-  railviz: true
-  with_shapes: true
-  adjust_footpaths: true
-  merge_dupes_intra_src: false
-  merge_dupes_inter_src: false
-  link_stop_distance: 100
-  update_interval: 60
-  http_timeout: 30
-  incremental_rt_update: false
-  use_osm_stop_coordinates: false
-  extend_missing_footpaths: false
-  max_footpath_length: 15
-  max_matching_distance: 25
-  datasets:
-`, filepath.Base(osmPath)))
-
-	// GTFS dataset entries
-	for _, file := range gtfsFiles {
-		key := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
-		config.WriteString(fmt.Sprintf("    %s:\n", key))
-		config.WriteString(fmt.Sprintf("      path: %s\n", filepath.Base(file)))
-		config.WriteString("      default_bikes_allowed: false\n")
+	GenerateConfigCommand(osmPath, gtfsFiles, outputPath)
+	if err := GenerateServerEnv(opts.Server, outputPath); err != nil {
+		return err
 	}
 
-	// Remaining static options
-	config.WriteString(`street_routing: true
-osr_footpath: false
-geocoding: true
-reverse_geocoding: true
-`)
+	config, err := RenderMotisConfig(osmPath, gtfsFiles, opts)
+	if err != nil {
+		return err
+	}
 
 	// Write to file
-	return os.WriteFile(outputPath+"config.yml", []byte(config.String()), 0644)
+	return os.WriteFile(outputPath+"config.yml", []byte(config), 0644)
+}
+
+// BuildMotisConfig assembles the config for the given OSM file, GTFS feeds
+// and options.
+func BuildMotisConfig(osmPath string, gtfsFiles []string, opts Options) Config {
+	profile := opts.Profile
+	server := opts.Server.WithDefaults()
+	config := Config{
+		Server: &Server{
+			Host:      server.Host,
+			Port:      server.Port,
+			WebFolder: server.WebFolder,
+			NThreads:  server.NThreads,
+		},
+		Osm:              filepath.Base(osmPath),
+		StreetRouting:    profile.StreetRouting,
+		OsrFootpath:      profile.OsrFootpath,
+		Geocoding:        profile.Geocoding,
+		ReverseGeocoding: profile.ReverseGeocoding,
+	}
+
+	if profile.Tiles {
+		config.Tiles = &Tiles{
+			Profile:        profile.TilesProfile,
+			DbSize:         274877906944,
+			FlushThreshold: 100000,
+		}
+	}
+
+	if profile.Timetable {
+		config.Timetable = &Timetable{
+			FirstDay:            "TODAY",
+			NumDays:             profile.NumDays,
+			Railviz:             profile.Railviz,
+			WithShapes:          profile.WithShapes,
+			AdjustFootpaths:     true,
+			LinkStopDistance:    100,
+			UpdateInterval:      60,
+			HttpTimeout:         30,
+			MaxFootpathLength:   15,
+			MaxMatchingDistance: 25,
+			Datasets:            map[string]Dataset{},
+		}
+
+		// GTFS dataset entries
+		for _, file := range gtfsFiles {
+			key := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+			datasetOpts := opts.Datasets[filepath.Base(file)]
+			config.Timetable.Datasets[key] = Dataset{
+				Path:                filepath.Base(file),
+				DefaultBikesAllowed: datasetOpts.BikesAllowed,
+				DefaultCarsAllowed:  datasetOpts.CarsAllowed,
+				ExtendCalendar:      datasetOpts.ExtendCalendar,
+				Clasz:               datasetOpts.Clasz,
+				Script:              datasetOpts.Script,
+			}
+			for _, url := range datasetOpts.RealtimeURLs {
+				dataset := config.Timetable.Datasets[key]
+				dataset.Rt = append(dataset.Rt, RtFeed{Url: url})
+				config.Timetable.Datasets[key] = dataset
+			}
+		}
+	}
+
+	return config
+}
+
+// RenderMotisConfig returns the config.yml content GenerateMotisConfig would
+// write, without touching the file system.
+func RenderMotisConfig(osmPath string, gtfsFiles []string, opts Options) (string, error) {
+	buf := bytes.Buffer{}
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(BuildMotisConfig(osmPath, gtfsFiles, opts)); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
 }
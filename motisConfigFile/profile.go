@@ -0,0 +1,221 @@
+package motisconfigfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultProfile is used when no profile is requested.
+const DefaultProfile = "full"
+
+// Profile bundles the MOTIS options that are enabled in a generated config.
+type Profile struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description"`
+	// Base names the profile whose options are used for every field a
+	// custom profile file does not set. It defaults to DefaultProfile.
+	Base string `yaml:"base,omitempty" json:"base,omitempty"`
+
+	Tiles        bool   `yaml:"tiles" json:"tiles"`
+	TilesProfile string `yaml:"tiles_profile" json:"tilesProfile"`
+
+	Timetable  bool `yaml:"timetable" json:"timetable"`
+	Railviz    bool `yaml:"railviz" json:"railviz"`
+	WithShapes bool `yaml:"with_shapes" json:"withShapes"`
+	NumDays    int  `yaml:"num_days" json:"numDays"`
+
+	StreetRouting    bool `yaml:"street_routing" json:"streetRouting"`
+	OsrFootpath      bool `yaml:"osr_footpath" json:"osrFootpath"`
+	Geocoding        bool `yaml:"geocoding" json:"geocoding"`
+	ReverseGeocoding bool `yaml:"reverse_geocoding" json:"reverseGeocoding"`
+
+	// Custom is true for profiles loaded from the workspace.
+	Custom bool `yaml:"-" json:"custom"`
+}
+
+var fullProfile = Profile{
+	Name:             "full",
+	Description:      "Routing, tiles, railviz and geocoding for public servers",
+	Tiles:            true,
+	TilesProfile:     "tiles-profiles/full.lua",
+	Timetable:        true,
+	Railviz:          true,
+	WithShapes:       true,
+	NumDays:          365,
+	StreetRouting:    true,
+	Geocoding:        true,
+	ReverseGeocoding: true,
+}
+
+// builtinProfiles returns the profiles shipped with the server.
+func builtinProfiles() map[string]Profile {
+	routingOnly := fullProfile
+	routingOnly.Name = "routing-only"
+	routingOnly.Description = "Lightweight routing without tiles, railviz and geocoding, e.g. for CI boxes"
+	routingOnly.Tiles = false
+	routingOnly.Railviz = false
+	routingOnly.WithShapes = false
+	routingOnly.Geocoding = false
+	routingOnly.ReverseGeocoding = false
+
+	streetOnly := fullProfile
+	streetOnly.Name = "street-only"
+	streetOnly.Description = "Street routing and geocoding without GTFS datasets"
+	streetOnly.Tiles = false
+	streetOnly.Timetable = false
+	streetOnly.Railviz = false
+	streetOnly.WithShapes = false
+
+	tilesOnly := fullProfile
+	tilesOnly.Name = "tiles-only"
+	tilesOnly.Description = "Vector tiles only, no routing and no geocoding"
+	tilesOnly.Timetable = false
+	tilesOnly.Railviz = false
+	tilesOnly.WithShapes = false
+	tilesOnly.StreetRouting = false
+	tilesOnly.Geocoding = false
+	tilesOnly.ReverseGeocoding = false
+
+	geocodingOff := fullProfile
+	geocodingOff.Name = "geocoding-off"
+	geocodingOff.Description = "Full profile without geocoding and reverse geocoding"
+	geocodingOff.Geocoding = false
+	geocodingOff.ReverseGeocoding = false
+
+	profiles := map[string]Profile{}
+	for _, p := range []Profile{fullProfile, routingOnly, streetOnly, tilesOnly, geocodingOff} {
+		profiles[p.Name] = p
+	}
+	return profiles
+}
+
+// customProfile is a profile file read from the workspace.
+type customProfile struct {
+	file string
+	base string
+	data []byte
+}
+
+// LoadProfiles returns the built-in profiles merged with the custom profiles
+// stored as *.yml or *.yaml files in dir. A missing dir is not an error.
+// Custom profiles may inherit from each other in any file order; a file
+// named like a built-in profile replaces it and inherits from it by default.
+func LoadProfiles(dir string) (map[string]Profile, error) {
+	builtin := builtinProfiles()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return builtin, nil
+		}
+		return nil, fmt.Errorf("failed to read profile directory: %w", err)
+	}
+
+	custom := map[string]customProfile{}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yml" && ext != ".yaml") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read profile %s: %w", entry.Name(), err)
+		}
+
+		header := struct {
+			Base string `yaml:"base"`
+		}{}
+		if err := yaml.Unmarshal(data, &header); err != nil {
+			return nil, fmt.Errorf("failed to parse profile %s: %w", entry.Name(), err)
+		}
+		if header.Base == "" {
+			header.Base = DefaultProfile
+		}
+		custom[strings.TrimSuffix(entry.Name(), ext)] = customProfile{file: entry.Name(), base: header.Base, data: data}
+	}
+
+	// Resolve the bases after all files are read, detecting cycles.
+	profiles := map[string]Profile{}
+	for name, p := range builtin {
+		profiles[name] = p
+	}
+	resolved := map[string]bool{}
+	resolving := map[string]bool{}
+	var resolve func(name string) (Profile, error)
+	resolve = func(name string) (Profile, error) {
+		c := custom[name]
+		if resolved[name] {
+			return profiles[name], nil
+		}
+		if resolving[name] {
+			return Profile{}, fmt.Errorf("profile %s: base profiles form a cycle", c.file)
+		}
+		resolving[name] = true
+
+		var base Profile
+		if _, ok := custom[c.base]; ok && c.base != name {
+			b, err := resolve(c.base)
+			if err != nil {
+				return Profile{}, err
+			}
+			base = b
+		} else if b, ok := builtin[c.base]; ok {
+			base = b
+		} else {
+			return Profile{}, fmt.Errorf("profile %s: unknown base profile %q", c.file, c.base)
+		}
+
+		// Decode on top of the base profile so unset fields are inherited.
+		profile := base
+		profile.Name = name
+		if err := yaml.Unmarshal(c.data, &profile); err != nil {
+			return Profile{}, fmt.Errorf("failed to parse profile %s: %w", c.file, err)
+		}
+		profile.Custom = true
+		profiles[name] = profile
+		resolved[name] = true
+		return profile, nil
+	}
+	for name := range custom {
+		if _, err := resolve(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return profiles, nil
+}
+
+// ListProfiles returns all profiles sorted by name.
+func ListProfiles(dir string) ([]Profile, error) {
+	profiles, err := LoadProfiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Profile, 0, len(profiles))
+	for _, p := range profiles {
+		result = append(result, p)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// GetProfile looks up a profile by name. An empty name selects DefaultProfile.
+func GetProfile(dir, name string) (Profile, error) {
+	if name == "" {
+		name = DefaultProfile
+	}
+	profiles, err := LoadProfiles(dir)
+	if err != nil {
+		return Profile{}, err
+	}
+	profile, ok := profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown profile %q", name)
+	}
+	return profile, nil
+}
@@ -0,0 +1,65 @@
+package motisconfigfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeProfiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadProfilesResolvesBasesInAnyOrder(t *testing.T) {
+	// "a-child" sorts before its base "z-parent".
+	dir := writeProfiles(t, map[string]string{
+		"a-child.yml":  "base: z-parent\nrailviz: false\n",
+		"z-parent.yml": "base: routing-only\nnum_days: 30\n",
+	})
+	profiles, err := LoadProfiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	child := profiles["a-child"]
+	if child.NumDays != 30 || child.Railviz || child.Tiles || !child.Custom {
+		t.Errorf("a-child = %+v, want num_days 30 from z-parent and tiles off from routing-only", child)
+	}
+}
+
+func TestLoadProfilesOverridesBuiltin(t *testing.T) {
+	dir := writeProfiles(t, map[string]string{"full.yml": "num_days: 7\n"})
+	profiles, err := LoadProfiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if full := profiles["full"]; full.NumDays != 7 || !full.Tiles {
+		t.Errorf("full = %+v, want the built-in full with num_days 7", full)
+	}
+}
+
+func TestLoadProfilesErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"cycle", map[string]string{"a.yml": "base: b\n", "b.yml": "base: a\n"}, "cycle"},
+		{"unknown base", map[string]string{"a.yml": "base: missing\n"}, "unknown base"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadProfiles(writeProfiles(t, tt.files))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadProfiles() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
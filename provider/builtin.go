@@ -0,0 +1,222 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maxiputz/motisConfigServer/scrapper"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+func init() {
+	RegisterOSM("geofabrik", newGeofabrik)
+	RegisterFeed("transitous", newTransitous)
+	RegisterFeed("mobilitydatabase", newMobilityDatabase)
+	RegisterRelease("github", newGitHub)
+
+	RegisterOSM("json", func(c Config) (OSMProvider, error) { return newJSON[scrapper.Region](c) })
+	RegisterFeed("json", func(c Config) (FeedProvider, error) { return newJSON[[]scrapper.Transitous](c) })
+	RegisterRelease("json", func(c Config) (ReleaseProvider, error) { return newJSON[[]scrapper.Release](c) })
+}
+
+// geofabrik reads the regions from the Geofabrik index, see scrapper.GetOsm.
+// URL selects another index-v1.json, e.g. a mirror or a local copy.
+type geofabrik struct {
+	name string
+	url  string
+}
+
+func newGeofabrik(c Config) (OSMProvider, error) {
+	return &geofabrik{name: c.name(), url: c.URL}, nil
+}
+
+func (g *geofabrik) Name() string { return g.name }
+
+func (g *geofabrik) Hosts() []string {
+	if g.url == "" {
+		return []string{"download.geofabrik.de:443"}
+	}
+	return sourceHosts(g.url)
+}
+
+func (g *geofabrik) Regions(ctx context.Context, opts scrapper.CrawlOptions) (scrapper.CrawlResult, error) {
+	return scrapper.GetOsmFrom(ctx, g.url, opts)
+}
+
+// transitous lists the feeds mirrored by Transitous.
+type transitous struct {
+	name string
+}
+
+func newTransitous(c Config) (FeedProvider, error) {
+	if c.URL != "" {
+		return nil, fmt.Errorf("transitous does not support a url")
+	}
+	return &transitous{name: c.name()}, nil
+}
+
+func (t *transitous) Name() string { return t.name }
+
+func (t *transitous) Hosts() []string { return []string{"api.transitous.org:443"} }
+
+func (t *transitous) Feeds(ctx context.Context) ([]scrapper.Transitous, error) {
+	return scrapper.GetProcesGTFSLinks(ctx)
+}
+
+// mobilityDatabase reads the feeds of the Mobility Database sources CSV,
+// from URL or scrapper.MobilityDatabaseURL. Feeds listed by transitous as
+// well keep their Transitous entry, see Set.Feeds:
+//
+//	feeds:
+//	  - type: transitous
+//	  - type: mobilitydatabase
+//	    url: /data/sources.csv
+type mobilityDatabase struct {
+	name string
+	url  string
+}
+
+func newMobilityDatabase(c Config) (FeedProvider, error) {
+	source := c.URL
+	if source == "" {
+		source = scrapper.MobilityDatabaseURL
+	}
+	return &mobilityDatabase{name: c.name(), url: source}, nil
+}
+
+func (m *mobilityDatabase) Name() string { return m.name }
+
+func (m *mobilityDatabase) Hosts() []string { return sourceHosts(m.url) }
+
+func (m *mobilityDatabase) Feeds(ctx context.Context) ([]scrapper.Transitous, error) {
+	data, err := readSource(ctx, m.url)
+	if err != nil {
+		return nil, err
+	}
+	return scrapper.ParseMobilityDatabase(bytes.NewReader(data))
+}
+
+// gitHub lists the releases of a GitHub repository, selected by the "repo"
+// option and defaulting to scrapper.MotisRepo.
+type gitHub struct {
+	name string
+	repo string
+}
+
+func newGitHub(c Config) (ReleaseProvider, error) {
+	repo := c.Options["repo"]
+	if repo == "" {
+		repo = scrapper.MotisRepo
+	}
+	if owner, name, ok := strings.Cut(repo, "/"); !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("invalid repo %q, expected owner/name", repo)
+	}
+	return &gitHub{name: c.name(), repo: repo}, nil
+}
+
+func (g *gitHub) Name() string { return g.name }
+
+func (g *gitHub) Hosts() []string { return []string{"api.github.com:443"} }
+
+func (g *gitHub) Releases(ctx context.Context) ([]scrapper.Release, error) {
+	return scrapper.FetchRepo(g.repo)
+}
+
+// jsonSource reads a catalog in the format this server stores it, from an
+// http(s) URL or a local file. It serves internal indexes and mirrors.
+type jsonSource[T any] struct {
+	name string
+	url  string
+}
+
+func newJSON[T any](c Config) (*jsonSource[T], error) {
+	if c.URL == "" {
+		return nil, fmt.Errorf("json provider needs a url")
+	}
+	return &jsonSource[T]{name: c.name(), url: c.URL}, nil
+}
+
+func (j *jsonSource[T]) Name() string { return j.name }
+
+func (j *jsonSource[T]) Hosts() []string { return sourceHosts(j.url) }
+
+func (j *jsonSource[T]) read(ctx context.Context) (T, error) {
+	var value T
+	data, err := readSource(ctx, j.url)
+	if err != nil {
+		return value, err
+	}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return value, fmt.Errorf("error parsing %s: %w", j.url, err)
+	}
+	return value, nil
+}
+
+func (j *jsonSource[T]) Regions(ctx context.Context, _ scrapper.CrawlOptions) (scrapper.CrawlResult, error) {
+	value, err := j.read(ctx)
+	region, _ := any(value).(scrapper.Region)
+	return scrapper.CrawlResult{Tree: region, Failures: []scrapper.CrawlFailure{}}, err
+}
+
+func (j *jsonSource[T]) Feeds(ctx context.Context) ([]scrapper.Transitous, error) {
+	value, err := j.read(ctx)
+	feeds, _ := any(value).([]scrapper.Transitous)
+	return feeds, err
+}
+
+func (j *jsonSource[T]) Releases(ctx context.Context) ([]scrapper.Release, error) {
+	value, err := j.read(ctx)
+	releases, _ := any(value).([]scrapper.Release)
+	scrapper.SortReleases(releases)
+	return releases, err
+}
+
+// isURL reports whether source is an http(s) URL rather than a file.
+func isURL(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+// readSource returns the content of an http(s) URL or a local file.
+func readSource(ctx context.Context, source string) ([]byte, error) {
+	if !isURL(source) {
+		return os.ReadFile(source)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s: %v", source, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching %s: %s", source, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// sourceHosts returns the "host:port" of an http(s) URL, none for files.
+func sourceHosts(source string) []string {
+	if !isURL(source) {
+		return nil
+	}
+	u, err := url.Parse(source)
+	if err != nil || u.Hostname() == "" {
+		return nil
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	return []string{net.JoinHostPort(u.Hostname(), port)}
+}
@@ -0,0 +1,334 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maxiputz/motisConfigServer/scrapper"
+	"os"
+	"sort"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Provider is a source of catalog data.
+type Provider interface {
+	// Name identifies the provider in logs and errors.
+	Name() string
+	// Hosts lists the "host:port" addresses the provider fetches from, used
+	// to check whether it is reachable. Local sources have none.
+	Hosts() []string
+}
+
+// OSMProvider provides the tree of regions with OSM extracts.
+type OSMProvider interface {
+	Provider
+	// Regions returns the region tree. Parts of the tree that could not be
+	// fetched are listed in the Failures of the result; opts.Progress is
+	// called while pages are crawled.
+	Regions(ctx context.Context, opts scrapper.CrawlOptions) (scrapper.CrawlResult, error)
+}
+
+// FeedProvider provides GTFS feeds.
+type FeedProvider interface {
+	Provider
+	Feeds(ctx context.Context) ([]scrapper.Transitous, error)
+}
+
+// ReleaseProvider provides MOTIS releases.
+type ReleaseProvider interface {
+	Provider
+	Releases(ctx context.Context) ([]scrapper.Release, error)
+}
+
+// Config configures one provider in the providers file.
+type Config struct {
+	// Type selects the registered provider, e.g. "geofabrik".
+	Type string `yaml:"type" json:"type"`
+	// Name overrides the name of the provider, defaults to Type.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+	// URL is an http(s) URL or a local file the provider reads from. Its
+	// meaning and default depend on the type.
+	URL string `yaml:"url,omitempty" json:"url,omitempty"`
+	// Options are type specific settings.
+	Options  map[string]string `yaml:"options,omitempty" json:"options,omitempty"`
+	Disabled bool              `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+}
+
+// name returns Name or, if empty, Type.
+func (c Config) name() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Type
+}
+
+// FileConfig lists the providers of each catalog.
+type FileConfig struct {
+	OSM      []Config `yaml:"osm" json:"osm"`
+	Feeds    []Config `yaml:"feeds" json:"feeds"`
+	Releases []Config `yaml:"releases" json:"releases"`
+}
+
+// DefaultConfig uses Geofabrik, Transitous and the GitHub releases of MOTIS.
+var DefaultConfig = FileConfig{
+	OSM:      []Config{{Type: "geofabrik"}},
+	Feeds:    []Config{{Type: "transitous"}},
+	Releases: []Config{{Type: "github"}},
+}
+
+var (
+	registryMu   sync.RWMutex
+	osmTypes     = map[string]func(Config) (OSMProvider, error){}
+	feedTypes    = map[string]func(Config) (FeedProvider, error){}
+	releaseTypes = map[string]func(Config) (ReleaseProvider, error){}
+)
+
+// RegisterOSM makes an OSM provider type available to the configuration.
+// Registering a type twice replaces the first factory.
+func RegisterOSM(typ string, factory func(Config) (OSMProvider, error)) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	osmTypes[typ] = factory
+}
+
+// RegisterFeed makes a feed provider type available to the configuration.
+func RegisterFeed(typ string, factory func(Config) (FeedProvider, error)) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	feedTypes[typ] = factory
+}
+
+// RegisterRelease makes a release provider type available to the
+// configuration.
+func RegisterRelease(typ string, factory func(Config) (ReleaseProvider, error)) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	releaseTypes[typ] = factory
+}
+
+// Types lists the registered provider types of each catalog, sorted.
+func Types() map[string][]string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return map[string][]string{
+		"osm":      sortedKeys(osmTypes),
+		"feeds":    sortedKeys(feedTypes),
+		"releases": sortedKeys(releaseTypes),
+	}
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// LoadConfig reads the providers of each catalog from a YAML file, e.g.
+//
+//	feeds:
+//	  - type: transitous
+//	  - type: json
+//	    name: internal
+//	    url: https://feeds.example.org/index.json
+//
+// A catalog missing in the file keeps its default providers. A missing
+// file yields DefaultConfig.
+func LoadConfig(path string) (FileConfig, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return DefaultConfig, nil
+	}
+	if err != nil {
+		return FileConfig{}, err
+	}
+	config := FileConfig{}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return FileConfig{}, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	if config.OSM == nil {
+		config.OSM = DefaultConfig.OSM
+	}
+	if config.Feeds == nil {
+		config.Feeds = DefaultConfig.Feeds
+	}
+	if config.Releases == nil {
+		config.Releases = DefaultConfig.Releases
+	}
+	return config, nil
+}
+
+// Set holds the enabled providers of each catalog.
+type Set struct {
+	OSMProviders     []OSMProvider
+	FeedProviders    []FeedProvider
+	ReleaseProviders []ReleaseProvider
+}
+
+// Build creates the enabled providers of config. Entries with an unknown
+// type or invalid settings are skipped and reported in the joined error.
+func Build(config FileConfig) (*Set, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	set := &Set{}
+	var errs []error
+	set.OSMProviders = build(config.OSM, osmTypes, "osm", &errs)
+	set.FeedProviders = build(config.Feeds, feedTypes, "feeds", &errs)
+	set.ReleaseProviders = build(config.Releases, releaseTypes, "releases", &errs)
+	return set, errors.Join(errs...)
+}
+
+func build[T any](configs []Config, types map[string]func(Config) (T, error), catalog string, errs *[]error) []T {
+	result := []T{}
+	for _, config := range configs {
+		if config.Disabled {
+			continue
+		}
+		factory, ok := types[config.Type]
+		if !ok {
+			*errs = append(*errs, fmt.Errorf("%s: unknown provider type %q", catalog, config.Type))
+			continue
+		}
+		p, err := factory(config)
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("%s: provider %s: %w", catalog, config.name(), err))
+			continue
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+// Regions returns the region tree of the first OSM provider with the
+// top-level regions of the others appended, and the failures of all
+// providers. Any failing provider fails the whole catalog, so a tree
+// missing a provider never replaces a complete one.
+func (s *Set) Regions(ctx context.Context, opts scrapper.CrawlOptions) (scrapper.CrawlResult, error) {
+	if len(s.OSMProviders) == 0 {
+		return scrapper.CrawlResult{}, errors.New("no OSM provider enabled")
+	}
+	result := scrapper.CrawlResult{Failures: []scrapper.CrawlFailure{}}
+	for i, p := range s.OSMProviders {
+		regions, err := p.Regions(ctx, opts)
+		if err != nil {
+			return scrapper.CrawlResult{}, fmt.Errorf("%s: %w", p.Name(), err)
+		}
+		result.Fetched += regions.Fetched
+		result.Failures = append(result.Failures, regions.Failures...)
+		if i == 0 {
+			result.Tree = regions.Tree
+			continue
+		}
+		result.Tree.Children = append(result.Tree.Children, regions.Tree.Children...)
+	}
+	return result, nil
+}
+
+// Feeds returns the feeds of all feed providers. A feed listed by several
+// providers, matched by Mobility Database id, source URL or download URL,
+// is kept once: from Transitous if it lists the feed, otherwise from the
+// first provider listing it.
+func (s *Set) Feeds(ctx context.Context) ([]scrapper.Transitous, error) {
+	if len(s.FeedProviders) == 0 {
+		return nil, errors.New("no feed provider enabled")
+	}
+	result := []scrapper.Transitous{}
+	index := map[string]int{}
+	fromTransitous := map[int]bool{}
+	for _, p := range s.FeedProviders {
+		feeds, err := p.Feeds(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p.Name(), err)
+		}
+		_, preferred := p.(*transitous)
+		for _, feed := range feeds {
+			keys := feedKeys(feed)
+			i, seen := len(result), false
+			for _, key := range keys {
+				if j, ok := index[key]; ok {
+					i, seen = j, true
+					break
+				}
+			}
+			switch {
+			case !seen:
+				result = append(result, feed)
+			case preferred && !fromTransitous[i]:
+				result[i] = feed
+			default:
+				continue
+			}
+			fromTransitous[i] = preferred
+			for _, key := range keys {
+				index[key] = i
+			}
+		}
+	}
+	return result, nil
+}
+
+// feedKeys returns the keys identifying the same feed across providers.
+func feedKeys(feed scrapper.Transitous) []string {
+	keys := []string{}
+	if feed.MdbID != "" {
+		keys = append(keys, "mdb:"+feed.MdbID)
+	}
+	if feed.SourceURL != "" {
+		keys = append(keys, "source:"+feed.SourceURL)
+	}
+	return append(keys, "url:"+feed.Url)
+}
+
+// Releases returns the releases of all release providers sorted newest
+// first. A tag published by several providers is kept once. Any provider
+// error fails the call, also a *scrapper.RateLimitError returned with
+// cached releases, so the catalog keeps its data and reports the error.
+func (s *Set) Releases(ctx context.Context) ([]scrapper.Release, error) {
+	if len(s.ReleaseProviders) == 0 {
+		return nil, errors.New("no release provider enabled")
+	}
+	result := []scrapper.Release{}
+	seen := map[string]bool{}
+	for _, p := range s.ReleaseProviders {
+		releases, err := p.Releases(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p.Name(), err)
+		}
+		for _, release := range releases {
+			if seen[release.TagName] {
+				continue
+			}
+			seen[release.TagName] = true
+			result = append(result, release)
+		}
+	}
+	scrapper.SortReleases(result)
+	return result, nil
+}
+
+// OSMHosts lists the hosts of all OSM providers.
+func (s *Set) OSMHosts() []string {
+	return hosts(s.OSMProviders)
+}
+
+// FeedHosts lists the hosts of all feed providers.
+func (s *Set) FeedHosts() []string {
+	return hosts(s.FeedProviders)
+}
+
+// ReleaseHosts lists the hosts of all release providers.
+func (s *Set) ReleaseHosts() []string {
+	return hosts(s.ReleaseProviders)
+}
+
+func hosts[T Provider](providers []T) []string {
+	result := []string{}
+	for _, p := range providers {
+		result = append(result, p.Hosts()...)
+	}
+	return result
+}
@@ -0,0 +1,53 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"maxiputz/motisConfigServer/scrapper"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFeeds(t *testing.T, name string, feeds []scrapper.Transitous) Config {
+	t.Helper()
+	data, err := json.Marshal(feeds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), name+".json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return Config{Type: "json", Name: name, URL: path}
+}
+
+func TestSetFeedsDedupes(t *testing.T) {
+	first := writeFeeds(t, "first", []scrapper.Transitous{
+		{Name: "de_metro.gtfs.zip", Url: "https://api.transitous.org/gtfs/de_metro.gtfs.zip", MdbID: "10"},
+		{Name: "de_bus.gtfs.zip", Url: "https://api.transitous.org/gtfs/de_bus.gtfs.zip", SourceURL: "https://bus.example/gtfs.zip"},
+	})
+	second := writeFeeds(t, "second", []scrapper.Transitous{
+		{Name: "de_Metro (mdb-10).gtfs.zip", Url: "https://files.mobilitydatabase.org/mdb-10/latest.zip", MdbID: "10"},
+		{Name: "de_Bus (mdb-11).gtfs.zip", Url: "https://files.mobilitydatabase.org/mdb-11/latest.zip", MdbID: "11", SourceURL: "https://bus.example/gtfs.zip"},
+		{Name: "de_Tram (mdb-12).gtfs.zip", Url: "https://files.mobilitydatabase.org/mdb-12/latest.zip", MdbID: "12"},
+		{Name: "de_tram.gtfs.zip", Url: "https://files.mobilitydatabase.org/mdb-12/latest.zip"},
+	})
+	set, err := Build(FileConfig{Feeds: []Config{first, second}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	feeds, err := set.Feeds(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, feed := range feeds {
+		names = append(names, feed.Name)
+	}
+	want := []string{"de_metro.gtfs.zip", "de_bus.gtfs.zip", "de_Tram (mdb-12).gtfs.zip"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("feeds = %v, want %v", names, want)
+	}
+}
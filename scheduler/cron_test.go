@@ -0,0 +1,62 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseNext(t *testing.T) {
+	// Wednesday, 2025-01-01 10:30 UTC.
+	from := time.Date(2025, 1, 1, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"45 */6 * * *", time.Date(2025, 1, 1, 12, 45, 0, 0, time.UTC)},
+		{"0,15 10 * * *", time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)},
+		{"0 3 * * mon-fri", time.Date(2025, 1, 2, 3, 0, 0, 0, time.UTC)},
+		{"0 3 * * sat-sun", time.Date(2025, 1, 4, 3, 0, 0, 0, time.UTC)},
+		{"0 3 * * mon-sun", time.Date(2025, 1, 2, 3, 0, 0, 0, time.UTC)},
+		{"0 3 * * 7", time.Date(2025, 1, 5, 3, 0, 0, 0, time.UTC)},
+		{"0 3 * * 5-7", time.Date(2025, 1, 3, 3, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 15 feb *", time.Date(2025, 2, 15, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either one matches.
+		{"0 0 13 * fri", time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2025, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{"@every 2h", time.Date(2025, 1, 1, 12, 30, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		schedule, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.expr, err)
+			continue
+		}
+		if got := schedule.Next(from); !got.Equal(tt.want) {
+			t.Errorf("Parse(%q).Next() = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * * mon-xyz",
+		"@every 30s",
+		"@every soon",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", expr)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxRuns bounds the run history kept in memory.
+const maxRuns = 200
+
+// Outcome is the result of a run.
+type Outcome string
+
+const (
+	OutcomeOK    Outcome = "ok"
+	OutcomeError Outcome = "error"
+)
+
+// Run is one execution of a job.
+type Run struct {
+	Job      string        `json:"job"`
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"`
+	// Took is Duration for display, e.g. "1.5s".
+	Took    string  `json:"took"`
+	Outcome Outcome `json:"outcome"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// JobStatus describes a scheduled job.
+type JobStatus struct {
+	Job      string    `json:"job"`
+	Schedule string    `json:"schedule"`
+	Next     time.Time `json:"next"`
+	Running  bool      `json:"running"`
+}
+
+type job struct {
+	name     string
+	expr     string
+	schedule Schedule
+	fn       func(context.Context) error
+	next     time.Time
+	running  bool
+}
+
+// Scheduler runs jobs on their schedules and keeps a history of the runs.
+type Scheduler struct {
+	mu   sync.Mutex
+	jobs map[string]*job
+	runs []Run
+	now  func() time.Time
+}
+
+// New creates an empty scheduler.
+func New() *Scheduler {
+	return &Scheduler{jobs: map[string]*job{}, now: time.Now}
+}
+
+// Add schedules fn under name with a cron expression, see Parse. A job of
+// the same name is replaced.
+func (s *Scheduler) Add(name, expr string, fn func(context.Context) error) error {
+	schedule, err := Parse(expr)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", name, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := schedule.Next(s.now())
+	if next.IsZero() {
+		return fmt.Errorf("job %s: %q never matches", name, expr)
+	}
+	s.jobs[name] = &job{name: name, expr: expr, schedule: schedule, fn: fn, next: next}
+	return nil
+}
+
+// Start runs due jobs until ctx is done. Jobs run in their own goroutine;
+// a job whose previous run has not finished is not started again.
+func (s *Scheduler) Start(ctx context.Context) {
+	for {
+		s.runDue(ctx)
+		// Wake up at the start of the next minute.
+		now := s.now()
+		timer := time.NewTimer(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+func (s *Scheduler) runDue(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for _, j := range s.jobs {
+		if j.running || j.next.IsZero() || now.Before(j.next) {
+			continue
+		}
+		j.running = true
+		j.next = j.schedule.Next(now)
+		go s.run(ctx, j)
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, j *job) {
+	started := s.now()
+	err := j.fn(ctx)
+	duration := s.now().Sub(started)
+
+	run := Run{Job: j.name, Started: started, Duration: duration, Took: duration.Round(time.Millisecond).String(), Outcome: OutcomeOK}
+	if err != nil {
+		run.Outcome = OutcomeError
+		run.Error = err.Error()
+		log.Printf("Scheduled %s failed after %s: %v", j.name, run.Took, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j.running = false
+	s.runs = append(s.runs, run)
+	if len(s.runs) > maxRuns {
+		s.runs = s.runs[len(s.runs)-maxRuns:]
+	}
+}
+
+// Runs returns the recorded runs of job, or of all jobs if job is empty,
+// newest first.
+func (s *Scheduler) Runs(job string) []Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := []Run{}
+	for i := len(s.runs) - 1; i >= 0; i-- {
+		if job == "" || s.runs[i].Job == job {
+			result = append(result, s.runs[i])
+		}
+	}
+	return result
+}
+
+// Jobs returns the scheduled jobs ordered by name.
+func (s *Scheduler) Jobs() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := []JobStatus{}
+	for _, j := range s.jobs {
+		result = append(result, JobStatus{Job: j.name, Schedule: j.expr, Next: j.next, Running: j.running})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Job < result[j].Job })
+	return result
+}
+
+// LoadConfig reads the cron expressions of the jobs from a YAML file
+// mapping job names to expressions, e.g.
+//
+//	geofabrik: "0 3 * * *"
+//	transitous: "@every 6h"
+//	motis: "off"
+//
+// Jobs missing in the file keep their default, "off" disables a job.
+// A missing file yields the defaults.
+func LoadConfig(path string, defaults map[string]string) (map[string]string, error) {
+	config := map[string]string{}
+	for name, expr := range defaults {
+		config[name] = expr
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	overrides := map[string]string{}
+	if err := yaml.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	for name, expr := range overrides {
+		config[name] = expr
+	}
+	return config, nil
+}
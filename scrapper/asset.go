@@ -0,0 +1,165 @@
+package scrapper
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/sys/cpu"
+)
+
+// Asset name tokens, normalised to GOOS and GOARCH values.
+var (
+	assetOS = map[string]string{
+		"linux":   "linux",
+		"windows": "windows",
+		"win":     "windows",
+		"win64":   "windows",
+		"macos":   "darwin",
+		"darwin":  "darwin",
+		"osx":     "darwin",
+		"apple":   "darwin",
+		"freebsd": "freebsd",
+	}
+	assetArch = map[string]string{
+		"amd64":   "amd64",
+		"x86_64":  "amd64",
+		"x64":     "amd64",
+		"win64":   "amd64",
+		"arm64":   "arm64",
+		"aarch64": "arm64",
+		"arm":     "arm",
+		"armv7":   "arm",
+		"armhf":   "arm",
+		"i386":    "386",
+		"i686":    "386",
+		// MOTIS never published 32 bit x86 builds, "x86" names x86-64.
+		"x86": "amd64",
+	}
+	assetLibc = map[string]string{
+		"gnu":   "gnu",
+		"glibc": "gnu",
+		"musl":  "musl",
+		"msvc":  "msvc",
+	}
+)
+
+// assetExtensions are stripped from asset names before parsing.
+var assetExtensions = []string{".tar.bz2", ".tar.gz", ".tar.xz", ".tgz", ".zip", ".exe"}
+
+// ParseAssetName classifies an asset like "motis-linux-amd64-noavx.tar.bz2"
+// by os, arch, libc and variant. Fields not found in the name are empty,
+// except the arch of MOTIS builds without one, which were all x86-64.
+func ParseAssetName(name string) (goos, goarch, libc, variant string) {
+	base := strings.ToLower(name)
+	for _, ext := range assetExtensions {
+		base = strings.TrimSuffix(base, ext)
+	}
+
+	variants := []string{}
+	for _, token := range strings.Split(base, "-") {
+		switch {
+		case token == "" || token == "motis":
+		case goos == "" && assetOS[token] != "":
+			goos = assetOS[token]
+			// "win64" names both.
+			if token == "win64" {
+				goarch = assetArch[token]
+			}
+		case goarch == "" && assetArch[token] != "":
+			goarch = assetArch[token]
+		case libc == "" && assetLibc[token] != "":
+			libc = assetLibc[token]
+		default:
+			variants = append(variants, token)
+		}
+	}
+	if goos != "" && goarch == "" {
+		goarch = "amd64"
+	}
+	return goos, goarch, libc, strings.Join(variants, "-")
+}
+
+// UnmarshalJSON decodes an asset and classifies it by its name, so cached
+// asset lists are reclassified whenever the parser changes.
+func (a *Asset) UnmarshalJSON(data []byte) error {
+	type plain Asset
+	if err := json.Unmarshal(data, (*plain)(a)); err != nil {
+		return err
+	}
+	a.Os, a.Arch, a.Libc, a.Variant = ParseAssetName(a.Name)
+	return nil
+}
+
+// Host describes the platform an asset should run on.
+type Host struct {
+	OS   string `json:"os"`
+	Arch string `json:"arch"`
+	Libc string `json:"libc,omitempty"`
+	// AVX2 is false on x86 CPUs without AVX2, which need a noavx build.
+	AVX2 bool `json:"avx2"`
+}
+
+// CurrentHost describes the machine the server runs on.
+func CurrentHost() Host {
+	host := Host{OS: runtime.GOOS, Arch: runtime.GOARCH, AVX2: true}
+	if runtime.GOARCH == "amd64" || runtime.GOARCH == "386" {
+		host.AVX2 = cpu.X86.HasAVX2
+	}
+	if runtime.GOOS == "linux" {
+		host.Libc = "gnu"
+		if musl, _ := filepath.Glob("/lib/ld-musl-*"); len(musl) > 0 {
+			host.Libc = "musl"
+		}
+	}
+	return host
+}
+
+// score rates how well the asset fits the host, 0 if it does not run there.
+func (h Host) score(a Asset) int {
+	if a.Os != h.OS || a.Arch != h.Arch {
+		return 0
+	}
+	if a.Libc != "" && h.Libc != "" && a.Libc != h.Libc {
+		return 0
+	}
+	noAVX := strings.Contains(a.Variant, "noavx")
+	switch {
+	case a.Variant == "" && !h.AVX2 && (a.Arch == "amd64" || a.Arch == "386"):
+		// The default x86 builds need AVX2.
+		return 0
+	case a.Variant == "", noAVX && !h.AVX2:
+		return 3
+	case noAVX:
+		// Runs everywhere, but slower than the default build.
+		return 2
+	}
+	return 1
+}
+
+// BestAsset returns the asset of the newest stable release that fits the
+// host best. releases are expected newest first, see SortReleases.
+func BestAsset(releases []Release, host Host) (Release, Asset, bool) {
+	for _, release := range releases {
+		if release.Prerelease || release.Draft {
+			continue
+		}
+		if asset, ok := BestReleaseAsset(release, host); ok {
+			return release, asset, true
+		}
+	}
+	return Release{}, Asset{}, false
+}
+
+// BestReleaseAsset returns the asset of release that fits the host best,
+// also for prereleases.
+func BestReleaseAsset(release Release, host Host) (Asset, bool) {
+	best, bestScore := Asset{}, 0
+	for _, asset := range release.Assets {
+		if score := host.score(asset); score > bestScore {
+			best, bestScore = asset, score
+		}
+	}
+	return best, bestScore > 0
+}
@@ -0,0 +1,31 @@
+package scrapper
+
+import "testing"
+
+func TestParseAssetName(t *testing.T) {
+	tests := []struct {
+		name                        string
+		goos, goarch, libc, variant string
+	}{
+		{"motis-linux-amd64.tar.bz2", "linux", "amd64", "", ""},
+		{"motis-linux-amd64-noavx.tar.bz2", "linux", "amd64", "", "noavx"},
+		{"motis-linux-arm64.tar.bz2", "linux", "arm64", "", ""},
+		{"motis-linux-aarch64-musl.tar.gz", "linux", "arm64", "musl", ""},
+		{"motis-linux-x86_64-gnu.tar.xz", "linux", "amd64", "gnu", ""},
+		{"motis-linux-armhf.tar.bz2", "linux", "arm", "", ""},
+		{"motis-windows.zip", "windows", "amd64", "", ""},
+		{"motis-win64-msvc.zip", "windows", "amd64", "msvc", ""},
+		{"motis-macos-arm64.tar.bz2", "darwin", "arm64", "", ""},
+		{"MOTIS-Linux-AMD64-Debug.tar.bz2", "linux", "amd64", "", "debug"},
+		{"motis-linux-amd64-noavx-debug.tgz", "linux", "amd64", "", "noavx-debug"},
+		{"motis-ui.tar.bz2", "", "", "", "ui"},
+		{"checksums.txt", "", "", "", "checksums.txt"},
+	}
+	for _, tt := range tests {
+		goos, goarch, libc, variant := ParseAssetName(tt.name)
+		if goos != tt.goos || goarch != tt.goarch || libc != tt.libc || variant != tt.variant {
+			t.Errorf("ParseAssetName(%q) = %q, %q, %q, %q, want %q, %q, %q, %q",
+				tt.name, goos, goarch, libc, variant, tt.goos, tt.goarch, tt.libc, tt.variant)
+		}
+	}
+}
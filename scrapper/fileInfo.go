@@ -0,0 +1,148 @@
+package scrapper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileInfo is the size and modification time of a remote file.
+type FileInfo struct {
+	Size         int64
+	LastModified *time.Time
+}
+
+// FetchFileInfo reads size and Last-Modified of url with a HEAD request.
+// Size is -1 if the server does not send a Content-Length.
+func FetchFileInfo(ctx context.Context, url string) (FileInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("error fetching %s: %v", url, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return FileInfo{}, fmt.Errorf("HTTP error: %s", resp.Status)
+	}
+
+	info := FileInfo{Size: resp.ContentLength}
+	if lastModified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.LastModified = &lastModified
+	}
+	return info, nil
+}
+
+// regionURL returns the absolute PBF URL of a region.
+func (r Region) regionURL() string {
+	if r.PbfURL != "" {
+		return r.PbfURL
+	}
+	if r.OsmData == "" {
+		return ""
+	}
+	// Same rule as the UI: scraped regions below depth 2 are relative to
+	// the page of their root.
+	if r.RootPath != "" {
+		return r.RawHtml + strings.TrimSuffix(r.RootPath, ".html") + "/" + r.OsmData
+	}
+	return r.RawHtml + "/" + r.OsmData
+}
+
+// Find returns the first region in the tree for which match is true.
+func (r Region) Find(match func(Region) bool) (Region, bool) {
+	if match(r) {
+		return r, true
+	}
+	for _, child := range r.Children {
+		if found, ok := child.Find(match); ok {
+			return found, true
+		}
+	}
+	return Region{}, false
+}
+
+// FindByURL returns the region whose PBF file is at url.
+func (r Region) FindByURL(url string) (Region, bool) {
+	return r.Find(func(region Region) bool {
+		return region.OsmData != "" && region.regionURL() == url
+	})
+}
+
+// listedSize matches the size Geofabrik lists next to a download link,
+// e.g. "(63.9 MB)".
+var listedSize = regexp.MustCompile(`^\(([0-9]+(?:\.[0-9]+)?)[\s\x{00a0}]*([KMGT]?B)\)$`)
+
+// parseListedSize returns the approximate size in bytes of a listing cell
+// such as "(63.9 MB)" and false for any other text.
+func parseListedSize(text string) (int64, bool) {
+	match := listedSize.FindStringSubmatch(strings.TrimSpace(text))
+	if match == nil {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, false
+	}
+	unit := float64(1)
+	for _, prefix := range "KMGT" {
+		unit *= 1024
+		if match[2][0] == byte(prefix) {
+			return int64(value * unit), true
+		}
+	}
+	return int64(value), true
+}
+
+// AnnotateFileInfo sets Size and LastModified of every region in the tree
+// from HEAD requests on the PBF files, using the worker pool and rate limit
+// of opts. Regions whose file info cannot be fetched keep their values,
+// e.g. the approximate size of the HTML listing.
+func AnnotateFileInfo(ctx context.Context, tree *Region, opts CrawlOptions) {
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = 250 * time.Millisecond
+	}
+	limiter := &hostLimiter{interval: opts.Interval, next: map[string]time.Time{}}
+	sem := make(chan struct{}, opts.Workers)
+
+	var wg sync.WaitGroup
+	var annotate func(region *Region)
+	annotate = func(region *Region) {
+		for i := range region.Children {
+			annotate(&region.Children[i])
+		}
+		url := region.regionURL()
+		if url == "" {
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if err := limiter.wait(ctx, url); err != nil {
+				return
+			}
+			info, err := FetchFileInfo(ctx, url)
+			if err != nil {
+				return
+			}
+			if info.Size >= 0 {
+				region.Size = info.Size
+			}
+			region.LastModified = info.LastModified
+		}()
+	}
+	annotate(tree)
+	wg.Wait()
+}
@@ -1,12 +1,11 @@
 package scrapper
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
-	"path/filepath"
+	"net/url"
 	"strings"
 	"time"
 
@@ -22,6 +21,18 @@ type Region struct {
 	RootPath   string   `json:"rootPath"`
 	RawHtml    string   `json:"rawHtml"`
 	Children   []Region `json:"children"`
+
+	// The fields below are only filled by GeofabrikIndex.
+	ID        string    `json:"id,omitempty"`
+	Parent    string    `json:"parent,omitempty"`
+	ISO3166_1 []string  `json:"iso3166_1,omitempty"`
+	ISO3166_2 []string  `json:"iso3166_2,omitempty"`
+	PbfURL    string    `json:"pbfUrl,omitempty"`
+	Geometry  *Geometry `json:"geometry,omitempty"`
+
+	// Size and LastModified describe the PBF file, see AnnotateFileInfo.
+	Size         int64      `json:"size,omitempty"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
 }
 
 // Geofabrik encapsulates the information needed to fetch a page.
@@ -49,9 +60,13 @@ func NewGeofabrik(path, prefix string, depth int, rootPath string) *Geofabrik {
 }
 
 // getHTML fetches and parses the HTML document at BaseURL+Path.
-func (g *Geofabrik) getHTML() (*goquery.Document, error) {
+func (g *Geofabrik) getHTML(ctx context.Context) (*goquery.Document, error) {
 	url := g.BaseURL + g.Path
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request for %s: %v", url, err)
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching %s: %v", url, err)
 	}
@@ -68,8 +83,8 @@ func (g *Geofabrik) getHTML() (*goquery.Document, error) {
 
 // getChildRaw finds all rows that contain a subregion.
 // It does so by selecting all td.subregion elements and retrieving their parent rows.
-func (g *Geofabrik) getChildRaw() ([]*goquery.Selection, error) {
-	doc, err := g.getHTML()
+func (g *Geofabrik) getChildRaw(ctx context.Context) ([]*goquery.Selection, error) {
+	doc, err := g.getHTML(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -86,8 +101,8 @@ func (g *Geofabrik) getChildRaw() ([]*goquery.Selection, error) {
 
 // getChildNodes extracts the data from each row into a Region object.
 // It filters out rows where the second cell does not contain an anchor.
-func (g *Geofabrik) getChildNodes() ([]Region, error) {
-	rows, err := g.getChildRaw()
+func (g *Geofabrik) getChildNodes(ctx context.Context) ([]Region, error) {
+	rows, err := g.getChildRaw(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -121,6 +136,12 @@ func (g *Geofabrik) getChildNodes() ([]Region, error) {
 			osmData = href
 		}
 
+		// The cell after the PBF link lists its size, e.g. "(63.9 MB)".
+		var size int64
+		if listed, ok := parseListedSize(cells.Eq(2).Text()); ok {
+			size = listed
+		}
+
 		// Determine if this is a leaf node by checking the last cell.
 		lastCell := cells.Last()
 		isLeaf := lastCell.Find("a").Length() > 0
@@ -133,140 +154,123 @@ func (g *Geofabrik) getChildNodes() ([]Region, error) {
 			RootPath:   g.RootPath,
 			RawHtml:    g.BaseURL,
 			Children:   nil,
+			Size:       size,
 		})
 	}
 	return regions, nil
 }
 
-// GetTree recursively builds the tree of regions.
-func (g *Geofabrik) GetTree() (Region, error) {
-	childNodes, err := g.getChildNodes()
-	if err != nil {
-		return Region{}, err
-	}
-
-	for i, child := range childNodes {
-		if !child.IsLeaf {
-			childPath := child.Path
-			// Adjust the path if the current depth is 2 or more.
-			if g.Depth >= 2 {
-				parts := strings.Split(strings.TrimPrefix(g.Path, "/"), "/")
-				if len(parts) > 0 {
-					childPath = parts[0] + "/" + child.Path
-				}
-			}
-			// Create a new instance for the child node.
-			node := NewGeofabrik(childPath, g.Prefix, g.Depth+1, g.Path)
-			subtree, err := node.GetTree()
-			if err != nil {
-				log.Printf("Error fetching tree for %s: %v", child.Path, err)
-				child.Children = []Region{}
-			} else {
-				child.Children = subtree.Children
-			}
-		} else {
-			child.Children = []Region{}
+// childGeofabrik returns the instance fetching the page of child.
+func (g *Geofabrik) childGeofabrik(child Region) *Geofabrik {
+	childPath := child.Path
+	// Adjust the path if the current depth is 2 or more.
+	if g.Depth >= 2 {
+		parts := strings.Split(strings.TrimPrefix(g.Path, "/"), "/")
+		if len(parts) > 0 {
+			childPath = parts[0] + "/" + child.Path
 		}
-		// Update the slice with the possibly modified child.
-		childNodes[i] = child
 	}
-
-	return Region{
-		Path:       g.Path,
-		IsLeaf:     false, // The current node is a directory.
-		RegionName: "",    // You can set a name for the root if desired.
-		OsmData:    "",
-		RootPath:   g.RootPath,
-		RawHtml:    g.BaseURL,
-		Children:   childNodes,
-	}, nil
+	node := NewGeofabrik(childPath, g.Prefix, g.Depth+1, g.Path)
+	node.BaseURL = g.BaseURL
+	return node
 }
 
+// GetOsm builds the region tree from the Geofabrik index and falls back to
+// scraping the HTML pages if the index cannot be read. The regions carry
+// the size and modification time of their PBF files.
 func GetOsm() (Region, error) {
-	root := NewGeofabrik("", "", 0, "")
-	tree, err := root.GetTree()
-	return tree, err
+	result, err := GetOsmFrom(context.Background(), "", CrawlOptions{})
+	return result.Tree, err
 }
 
-func UpdateGeofabrik() {
-	// Create the root Geofabrik instance.
-	root := NewGeofabrik("", "", 0, "")
-	tree, err := root.GetTree()
+// GetOsmFrom is GetOsm reading the index from source, see NewGeofabrikIndex.
+// If the index is served over http(s), the HTML pages are scraped from the
+// same site. Pages that fail while scraping are listed in the Failures of
+// the result, opts.Progress is called for every scraped page.
+func GetOsmFrom(ctx context.Context, source string, opts CrawlOptions) (CrawlResult, error) {
+	index := NewGeofabrikIndex(source)
+	tree, err := index.GetTree()
+	result := CrawlResult{Tree: tree, Failures: []CrawlFailure{}}
 	if err != nil {
-		log.Fatalf("Error getting tree: %v", err)
-	}
-	fmt.Printf("Tree: %+v\n", tree)
+		log.Printf("Error reading Geofabrik index, scraping HTML instead: %v", err)
 
-	// Serialize tree to JSON
-	data, err := json.MarshalIndent(tree, "", " ")
-	if err != nil {
-		log.Fatalf("Error marshalling tree to JSON: %v", err)
-	}
-
-	// Write new data to a temporary file.
-	tmpFile := "assets/tmp/geofabrik.json"
-	err = os.WriteFile(tmpFile, data, 0664)
-	if err != nil {
-		log.Fatalf("Error writing tmp file: %v", err)
-	}
-	_, err = os.Stat("assets/issueGeoFarbik")
-
-	if err == nil {
-		os.Remove("assets/issueGeoFarbik")
-		log.Printf("Warning: Could not remove tmp file: %v", err)
+		root := NewGeofabrik("", "", 0, "")
+		if u, err := url.Parse(index.Source); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			root.BaseURL = u.Scheme + "://" + u.Host
+		}
+		result, err = root.Crawl(ctx, opts)
+		if err != nil {
+			return result, err
+		}
 	}
 
-	// Define the main file path.
-	mainFile := "assets/geofabrik.json"
+	AnnotateFileInfo(ctx, &result.Tree, opts)
+	return result, ctx.Err()
+}
 
-	// Check if the main file exists.
-	oldData, err := os.ReadFile(mainFile)
-	if err != nil {
+// RegionSummary is a Region without children and geometry, used where the
+// full subtree is not needed.
+type RegionSummary struct {
+	ID           string     `json:"id,omitempty"`
+	Parent       string     `json:"parent,omitempty"`
+	Path         string     `json:"path"`
+	IsLeaf       bool       `json:"isLeaf"`
+	RegionName   string     `json:"regionName"`
+	OsmData      string     `json:"osmData"`
+	PbfURL       string     `json:"pbfUrl,omitempty"`
+	ISO3166_1    []string   `json:"iso3166_1,omitempty"`
+	ISO3166_2    []string   `json:"iso3166_2,omitempty"`
+	Size         int64      `json:"size,omitempty"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+	ChildCount   int        `json:"childCount"`
+}
 
-		fmt.Println("No changes detected. The main file remains unchanged.")
-		if os.IsNotExist(err) {
-			// No existing file: move the new file into place.
-			err = os.Rename(tmpFile, mainFile)
-			if err != nil {
-				log.Fatalf("Error moving new file to main location: %v", err)
-			}
-			fmt.Println("No previous file found. New file moved to assets/geofabrik.json")
-			return
-		}
-		log.Fatalf("Error reading existing main file: %v", err)
+// Key identifies the region in the tree: the Geofabrik id if known and
+// the PBF path without suffix otherwise, e.g. europe/germany.
+func (r Region) Key() string {
+	if r.ID != "" {
+		return r.ID
 	}
+	return strings.TrimSuffix(r.OsmData, "-latest.osm.pbf")
+}
 
-	// Compare the existing file with the new data.
-	if string(oldData) != string(data) {
-		// Files are different, so archive the old file.
-		archiveDir := "assets/archive"
-		err = os.MkdirAll(archiveDir, 0755)
-		if err != nil {
-
-			log.Fatalf("Error creating archive directory: %v", err)
-		}
-		// Archive file name, for example: geofabrik-2025-03-18.json.
-		archiveFile := filepath.Join(archiveDir, fmt.Sprintf("geofabrik-%s.json", time.Now().Format("2006-01-02")))
-		err = os.Rename(mainFile, archiveFile)
-		if err != nil {
-			log.Fatalf("Error archiving old file: %v", err)
+// WithoutGeometry returns a copy of the tree without region geometries.
+func (r Region) WithoutGeometry() Region {
+	r.Geometry = nil
+	if r.Children != nil {
+		children := make([]Region, len(r.Children))
+		for i, child := range r.Children {
+			children[i] = child.WithoutGeometry()
 		}
-		fmt.Printf("Old file archived to: %s\n", archiveFile)
+		r.Children = children
+	}
+	return r
+}
 
-		// Move the new file to the main location.
-		err = os.Rename(tmpFile, mainFile)
-		if err != nil {
-			log.Fatalf("Error moving new file to main location: %v", err)
-		}
-		fmt.Println("New file moved to assets/geofabrik.json")
+// Summary returns the region without its children and geometry.
+func (r Region) Summary() RegionSummary {
+	return RegionSummary{
+		ID:           r.Key(),
+		Parent:       r.Parent,
+		Path:         r.Path,
+		IsLeaf:       r.IsLeaf,
+		RegionName:   r.RegionName,
+		OsmData:      r.OsmData,
+		PbfURL:       r.regionURL(),
+		ISO3166_1:    r.ISO3166_1,
+		ISO3166_2:    r.ISO3166_2,
+		Size:         r.Size,
+		LastModified: r.LastModified,
+		ChildCount:   len(r.Children),
+	}
+}
 
-		// Create the issue file to notify the maintainer.
-		issueFile := "assets/issueGeoFarbik"
-		issueContent := []byte("Geofabrik tree file has changed. Please review the updates.")
-		err = os.WriteFile(issueFile, issueContent, 0664)
-		if err != nil {
-			log.Fatalf("Error creating issue file: %v", err)
-		}
-		fmt.Printf("Issue file created: %s\n", issueFile)
+// Flatten returns the summaries of all regions below r in depth-first order.
+func (r Region) Flatten() []RegionSummary {
+	result := []RegionSummary{}
+	for _, child := range r.Children {
+		result = append(result, child.Summary())
+		result = append(result, child.Flatten()...)
 	}
+	return result
 }
@@ -0,0 +1,182 @@
+package scrapper
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// CrawlOptions control how Geofabrik.Crawl fetches the region pages.
+type CrawlOptions struct {
+	// Workers is the number of pages fetched at the same time, default 4.
+	Workers int
+	// Interval is the minimum time between two requests to the same host,
+	// default 250ms.
+	Interval time.Duration
+	// Progress is called after every fetched page.
+	Progress func(CrawlProgress)
+}
+
+// CrawlProgress reports how far a crawl has come.
+type CrawlProgress struct {
+	Fetched   int `json:"fetched"`
+	Remaining int `json:"remaining"`
+}
+
+// CrawlFailure is a region page that could not be fetched. Its subtree is
+// left empty in the crawled tree.
+type CrawlFailure struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// CrawlResult is the crawled tree together with a summary of the crawl.
+type CrawlResult struct {
+	Tree     Region         `json:"tree"`
+	Fetched  int            `json:"fetched"`
+	Failures []CrawlFailure `json:"failures"`
+}
+
+// hostLimiter spaces out requests to the same host.
+type hostLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     map[string]time.Time
+}
+
+// wait blocks until the next request to the host of rawURL may be sent.
+func (l *hostLimiter) wait(ctx context.Context, rawURL string) error {
+	host := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		host = u.Host
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next[host]
+	if at.Before(now) {
+		at = now
+	}
+	l.next[host] = at.Add(l.interval)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Crawl builds the tree of regions, fetching the pages of non-leaf regions
+// on a bounded worker pool with a per-host rate limit. Failed subtrees are
+// reported in the result instead of aborting the crawl; only a failure of
+// the root page or a cancelled ctx is returned as error.
+func (g *Geofabrik) Crawl(ctx context.Context, opts CrawlOptions) (CrawlResult, error) {
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = 250 * time.Millisecond
+	}
+	limiter := &hostLimiter{interval: opts.Interval, next: map[string]time.Time{}}
+	sem := make(chan struct{}, opts.Workers)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	result := CrawlResult{Failures: []CrawlFailure{}}
+	remaining := 0
+
+	fetch := func(node *Geofabrik) ([]Region, error) {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		defer func() { <-sem }()
+		if err := limiter.wait(ctx, node.BaseURL+node.Path); err != nil {
+			return nil, err
+		}
+		return node.getChildNodes(ctx)
+	}
+
+	// crawl fetches the page of node and stores its children in dst. Each
+	// dst is written by exactly one goroutine before wg.Wait returns.
+	var crawl func(node *Geofabrik, dst *[]Region)
+	crawl = func(node *Geofabrik, dst *[]Region) {
+		defer wg.Done()
+		children, err := fetch(node)
+
+		mu.Lock()
+		remaining--
+		if err != nil {
+			result.Failures = append(result.Failures, CrawlFailure{Path: node.Path, Error: err.Error()})
+			progress := CrawlProgress{Fetched: result.Fetched, Remaining: remaining}
+			mu.Unlock()
+
+			*dst = []Region{}
+			if opts.Progress != nil {
+				opts.Progress(progress)
+			}
+			return
+		}
+		result.Fetched++
+		for i := range children {
+			children[i].Children = []Region{}
+			if !children[i].IsLeaf {
+				remaining++
+			}
+		}
+		progress := CrawlProgress{Fetched: result.Fetched, Remaining: remaining}
+		mu.Unlock()
+
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+
+		*dst = children
+		for i := range children {
+			if !children[i].IsLeaf {
+				wg.Add(1)
+				go crawl(node.childGeofabrik(children[i]), &children[i].Children)
+			}
+		}
+	}
+
+	// The root page is fetched first so that its failure can be returned.
+	rootChildren, err := fetch(g)
+	if err != nil {
+		return result, err
+	}
+	result.Fetched++
+	for i := range rootChildren {
+		rootChildren[i].Children = []Region{}
+		if !rootChildren[i].IsLeaf {
+			remaining++
+			wg.Add(1)
+		}
+	}
+	if opts.Progress != nil {
+		opts.Progress(CrawlProgress{Fetched: result.Fetched, Remaining: remaining})
+	}
+	for i := range rootChildren {
+		if !rootChildren[i].IsLeaf {
+			go crawl(g.childGeofabrik(rootChildren[i]), &rootChildren[i].Children)
+		}
+	}
+	wg.Wait()
+
+	result.Tree = Region{
+		Path:       g.Path,
+		IsLeaf:     false, // The current node is a directory.
+		RegionName: "",
+		OsmData:    "",
+		RootPath:   g.RootPath,
+		RawHtml:    g.BaseURL,
+		Children:   rootChildren,
+	}
+	return result, ctx.Err()
+}
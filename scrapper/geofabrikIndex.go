@@ -0,0 +1,156 @@
+package scrapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+// GeofabrikIndexURL is the GeoJSON catalog of all Geofabrik extracts.
+const GeofabrikIndexURL = "https://download.geofabrik.de/index-v1.json"
+
+// Geometry is a GeoJSON Polygon or MultiPolygon of a region.
+type Geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// GeofabrikIndex builds the region tree from Geofabrik's index-v1.json
+// instead of scraping the HTML pages.
+type GeofabrikIndex struct {
+	// Source is an http(s) URL or a path to a local index-v1.json file.
+	Source  string
+	BaseURL string
+}
+
+type geofabrikIndexFile struct {
+	Features []struct {
+		Properties struct {
+			ID        string            `json:"id"`
+			Parent    string            `json:"parent"`
+			Name      string            `json:"name"`
+			ISO3166_1 []string          `json:"iso3166-1:alpha2"`
+			ISO3166_2 []string          `json:"iso3166-2"`
+			Urls      map[string]string `json:"urls"`
+		} `json:"properties"`
+		Geometry *Geometry `json:"geometry"`
+	} `json:"features"`
+}
+
+// NewGeofabrikIndex creates a provider reading the index from source.
+// An empty source selects GeofabrikIndexURL.
+func NewGeofabrikIndex(source string) *GeofabrikIndex {
+	if source == "" {
+		source = GeofabrikIndexURL
+	}
+	return &GeofabrikIndex{
+		Source:  source,
+		BaseURL: "https://download.geofabrik.de",
+	}
+}
+
+// read returns the raw index from a URL or a local file.
+func (g *GeofabrikIndex) read() ([]byte, error) {
+	if !strings.HasPrefix(g.Source, "http://") && !strings.HasPrefix(g.Source, "https://") {
+		return os.ReadFile(g.Source)
+	}
+	resp, err := http.Get(g.Source)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s: %v", g.Source, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP error: %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// GetTree builds the region tree. Regions are keyed by their Geofabrik id
+// and nested below their parent; children are sorted by name.
+func (g *GeofabrikIndex) GetTree() (Region, error) {
+	data, err := g.read()
+	if err != nil {
+		return Region{}, err
+	}
+	index := geofabrikIndexFile{}
+	if err := json.Unmarshal(data, &index); err != nil {
+		return Region{}, fmt.Errorf("error parsing Geofabrik index: %v", err)
+	}
+
+	regions := map[string]Region{}
+	children := map[string][]string{}
+	for _, feature := range index.Features {
+		props := feature.Properties
+		if props.ID == "" {
+			continue
+		}
+		pbfURL := props.Urls["pbf"]
+		osmData := strings.TrimPrefix(strings.TrimPrefix(pbfURL, g.BaseURL), "/")
+		regions[props.ID] = Region{
+			ID:         props.ID,
+			Parent:     props.Parent,
+			Path:       strings.TrimSuffix(osmData, "-latest.osm.pbf") + ".html",
+			RegionName: props.Name,
+			OsmData:    osmData,
+			PbfURL:     pbfURL,
+			ISO3166_1:  props.ISO3166_1,
+			ISO3166_2:  props.ISO3166_2,
+			Geometry:   feature.Geometry,
+			RawHtml:    g.BaseURL,
+		}
+		children[props.Parent] = append(children[props.Parent], props.ID)
+	}
+
+	// Regions whose parent is not part of the index become top-level nodes.
+	for parent, ids := range children {
+		if _, ok := regions[parent]; parent != "" && !ok {
+			children[""] = append(children[""], ids...)
+			delete(children, parent)
+		}
+	}
+
+	var build func(parent string) []Region
+	build = func(parent string) []Region {
+		nodes := []Region{}
+		for _, id := range children[parent] {
+			node := regions[id]
+			node.Children = build(id)
+			node.IsLeaf = len(node.Children) == 0
+			nodes = append(nodes, node)
+		}
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i].RegionName < nodes[j].RegionName })
+		return nodes
+	}
+
+	return Region{
+		Path:     "",
+		IsLeaf:   false,
+		RawHtml:  g.BaseURL,
+		Children: build(""),
+	}, nil
+}
+
+// Polygons returns the outer and inner rings of a Polygon or MultiPolygon
+// geometry as [lon, lat] coordinates.
+func (g *Geometry) Polygons() ([][][][2]float64, error) {
+	switch g.Type {
+	case "Polygon":
+		polygon := [][][2]float64{}
+		if err := json.Unmarshal(g.Coordinates, &polygon); err != nil {
+			return nil, err
+		}
+		return [][][][2]float64{polygon}, nil
+	case "MultiPolygon":
+		polygons := [][][][2]float64{}
+		if err := json.Unmarshal(g.Coordinates, &polygons); err != nil {
+			return nil, err
+		}
+		return polygons, nil
+	default:
+		return nil, fmt.Errorf("unsupported geometry type %q", g.Type)
+	}
+}
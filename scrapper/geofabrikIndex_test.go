@@ -0,0 +1,161 @@
+package scrapper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGeofabrikIndexGetTree(t *testing.T) {
+	tree, err := NewGeofabrikIndex("testdata/index-v1.json").GetTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// California's parent is not part of the index, so it is a top-level node.
+	var names []string
+	for _, region := range tree.Children {
+		names = append(names, region.RegionName)
+	}
+	if want := []string{"California", "Europe"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("top-level regions = %v, want %v", names, want)
+	}
+
+	europe := tree.Children[1]
+	if europe.Geometry == nil || europe.Geometry.Type != "Polygon" || europe.IsLeaf {
+		t.Errorf("europe = %+v, want a non-leaf region with a polygon", europe)
+	}
+	if len(europe.Children) != 2 || europe.Children[0].ID != "austria" || europe.Children[1].ID != "germany" {
+		t.Fatalf("children of europe = %+v, want austria and germany", europe.Children)
+	}
+
+	germany := europe.Children[1]
+	if germany.Parent != "europe" || !reflect.DeepEqual(germany.ISO3166_1, []string{"DE"}) {
+		t.Errorf("germany parent = %q, iso3166-1 = %v", germany.Parent, germany.ISO3166_1)
+	}
+	if germany.Path != "europe/germany.html" || germany.OsmData != "europe/germany-latest.osm.pbf" {
+		t.Errorf("germany path = %q, osmData = %q", germany.Path, germany.OsmData)
+	}
+
+	if len(germany.Children) != 1 {
+		t.Fatalf("children of germany = %+v, want berlin", germany.Children)
+	}
+	berlin := germany.Children[0]
+	if berlin.Parent != "germany" || !berlin.IsLeaf || !reflect.DeepEqual(berlin.ISO3166_2, []string{"DE-BE"}) {
+		t.Errorf("berlin = %+v, want a leaf below germany with iso3166-2 DE-BE", berlin)
+	}
+	if got, want := berlin.Summary().PbfURL, "https://download.geofabrik.de/europe/germany/berlin-latest.osm.pbf"; got != want {
+		t.Errorf("berlin pbf url = %q, want %q", got, want)
+	}
+}
+
+func TestGetOsmFromAnnotatesIndexRegions(t *testing.T) {
+	modified := time.Date(2025, 3, 18, 20, 21, 44, 0, time.UTC)
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index-v1.json":
+			fmt.Fprintf(w, `{"features": [
+				{"properties": {"id": "europe", "name": "Europe", "urls": {"pbf": "%[1]s/europe-latest.osm.pbf"}}},
+				{"properties": {"id": "malta", "parent": "europe", "name": "Malta", "urls": {"pbf": "%[1]s/europe/malta-latest.osm.pbf"}}}
+			]}`, server.URL)
+		case "/europe/malta-latest.osm.pbf":
+			w.Header().Set("Content-Length", "7340032")
+			w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	result, err := GetOsmFrom(context.Background(), server.URL+"/index-v1.json", CrawlOptions{Interval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	europe := result.Tree.Children[0]
+	if europe.Size != 0 || europe.LastModified != nil {
+		t.Errorf("europe size = %d, last modified = %v, want none for a failed HEAD", europe.Size, europe.LastModified)
+	}
+	malta := europe.Children[0]
+	if malta.Size != 7340032 || malta.LastModified == nil || !malta.LastModified.Equal(modified) {
+		t.Errorf("malta size = %d, last modified = %v, want 7340032 and %v", malta.Size, malta.LastModified, modified)
+	}
+}
+
+const rootPage = `<table>
+<tr><td class="subregion"><a href="europe.html">Europe</a></td><td><a href="europe-latest.osm.pbf">[.osm.pbf]</a></td><td>(27.5&nbsp;GB)</td><td></td></tr>
+<tr><td class="subregion"><a href="antarctica.html">Antarctica</a></td><td><a href="antarctica-latest.osm.pbf">[.osm.pbf]</a></td><td>(30.1 MB)</td><td><a href="antarctica-latest.osm.bz2">[.osm.bz2]</a></td></tr>
+</table>`
+
+const europePage = `<table>
+<tr><td class="subregion"><a href="europe/malta.html">Malta</a></td><td><a href="europe/malta-latest.osm.pbf">[.osm.pbf]</a></td><td>(7 MB)</td><td><a href="europe/malta-latest.osm.bz2">[.osm.bz2]</a></td></tr>
+</table>`
+
+func TestGetOsmFromFallsBackToHTML(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/", "":
+			w.Write([]byte(rootPage))
+		case "/europe.html":
+			w.Write([]byte(europePage))
+		default:
+			http.Error(w, "not found", http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	result, err := GetOsmFrom(context.Background(), server.URL+"/index-v1.json", CrawlOptions{Interval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Failures) != 0 || result.Fetched != 2 {
+		t.Errorf("fetched = %d, failures = %+v, want 2 pages and no failures", result.Fetched, result.Failures)
+	}
+
+	tree := result.Tree
+	if len(tree.Children) != 2 {
+		t.Fatalf("top-level regions = %+v, want europe and antarctica", tree.Children)
+	}
+	europe, antarctica := tree.Children[0], tree.Children[1]
+	if europe.IsLeaf || !antarctica.IsLeaf {
+		t.Errorf("europe leaf = %v, antarctica leaf = %v", europe.IsLeaf, antarctica.IsLeaf)
+	}
+	if europe.Size != 27.5*1024*1024*1024 || antarctica.Size != 31562137 {
+		t.Errorf("sizes = %d, %d, want the listed sizes", europe.Size, antarctica.Size)
+	}
+	if len(europe.Children) != 1 || europe.Children[0].RegionName != "Malta" {
+		t.Fatalf("children of europe = %+v, want malta", europe.Children)
+	}
+	malta := europe.Children[0]
+	if got, want := malta.Summary().PbfURL, server.URL+"/europe/malta-latest.osm.pbf"; got != want {
+		t.Errorf("malta pbf url = %q, want %q", got, want)
+	}
+	if malta.Key() != "europe/malta" {
+		t.Errorf("malta key = %q, want the path based key", malta.Key())
+	}
+}
+
+func TestParseListedSize(t *testing.T) {
+	tests := []struct {
+		text string
+		want int64
+		ok   bool
+	}{
+		{"(63.9 MB)", 67004006, true},
+		{" (2 GB) ", 2 << 30, true},
+		{"(12 KB)", 12 << 10, true},
+		{"(512 B)", 512, true},
+		{"[.osm.pbf]", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseListedSize(tt.text)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseListedSize(%q) = %d, %v, want %d, %v", tt.text, got, ok, tt.want, tt.ok)
+		}
+	}
+}
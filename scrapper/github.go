@@ -0,0 +1,200 @@
+package scrapper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// GitHubTokenEnv names the environment variable holding a GitHub token.
+const GitHubTokenEnv = "GITHUB_TOKEN"
+
+// RateLimitError is returned while GitHub throttles the client.
+type RateLimitError struct {
+	Reset time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("GitHub rate limit exceeded, resets at %s", e.Reset.Format(time.RFC3339))
+}
+
+// RateLimit is the request budget GitHub reported with the last response.
+type RateLimit struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+// GitHubClient fetches from the GitHub API. Responses are cached with their
+// ETag in CacheDir, so unchanged resources are revalidated with conditional
+// requests, which do not count against the rate limit.
+type GitHubClient struct {
+	// Token authenticates requests, raising the limit from 60 to 5000
+	// requests per hour. Anonymous if empty.
+	Token string
+	// CacheDir keeps the cached responses, caching is off if empty.
+	CacheDir string
+
+	mu        sync.Mutex
+	rateLimit *RateLimit
+}
+
+// GitHub is the client used for the MOTIS releases. Its token defaults to
+// the GITHUB_TOKEN environment variable.
+var GitHub = &GitHubClient{Token: os.Getenv(GitHubTokenEnv)}
+
+// cachedResponse is a response body stored with its ETag.
+type cachedResponse struct {
+	ETag string          `json:"etag"`
+	Body json.RawMessage `json:"body"`
+}
+
+// RateLimit returns the budget reported by GitHub, nil before the first
+// response.
+func (g *GitHubClient) RateLimit() *RateLimit {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.rateLimit == nil {
+		return nil
+	}
+	limit := *g.rateLimit
+	return &limit
+}
+
+// GetJSON decodes the JSON resource at url into v. It fails with a
+// *RateLimitError without sending a request while the budget is used up.
+func (g *GitHubClient) GetJSON(url string, v any) error {
+	return g.GetJSONContext(context.Background(), url, v)
+}
+
+// GetJSONContext is GetJSON with a context for the request.
+func (g *GitHubClient) GetJSONContext(ctx context.Context, url string, v any) error {
+	if limit := g.RateLimit(); limit != nil && limit.Remaining == 0 && time.Now().Before(limit.Reset) {
+		return &RateLimitError{Reset: limit.Reset}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if g.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.Token)
+	}
+	cached, hasCache := g.loadCache(url)
+	if hasCache {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	g.updateRateLimit(resp.Header)
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && hasCache:
+		return json.Unmarshal(cached.Body, v)
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests:
+		if reset, throttled := throttledUntil(resp.Header); throttled {
+			return &RateLimitError{Reset: reset}
+		}
+		return fmt.Errorf("HTTP error: %s", resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("HTTP error: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return err
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		g.saveCache(url, cachedResponse{ETag: etag, Body: body})
+	}
+	return nil
+}
+
+func (g *GitHubClient) updateRateLimit(header http.Header) {
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	limit, _ := strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	reset, _ := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.rateLimit = &RateLimit{Limit: limit, Remaining: remaining, Reset: time.Unix(reset, 0)}
+}
+
+// throttledUntil tells whether a 403 or 429 response is a primary or
+// secondary rate limit and when to try again.
+func throttledUntil(header http.Header) (time.Time, bool) {
+	if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil {
+		return time.Now().Add(time.Duration(seconds) * time.Second), true
+	}
+	if header.Get("X-RateLimit-Remaining") == "0" {
+		reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+		if err == nil {
+			return time.Unix(reset, 0), true
+		}
+		return time.Now().Add(time.Minute), true
+	}
+	return time.Time{}, false
+}
+
+func (g *GitHubClient) cachePath(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(g.CacheDir, hex.EncodeToString(sum[:8])+".json")
+}
+
+func (g *GitHubClient) loadCache(url string) (cachedResponse, bool) {
+	cached := cachedResponse{}
+	if g.CacheDir == "" {
+		return cached, false
+	}
+	data, err := os.ReadFile(g.cachePath(url))
+	if err != nil {
+		return cached, false
+	}
+	if err := json.Unmarshal(data, &cached); err != nil || cached.ETag == "" {
+		return cached, false
+	}
+	return cached, true
+}
+
+func (g *GitHubClient) saveCache(url string, cached cachedResponse) {
+	if g.CacheDir == "" {
+		return
+	}
+	data, err := json.Marshal(cached)
+	if err == nil {
+		err = os.MkdirAll(g.CacheDir, 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(g.cachePath(url), data, 0644)
+	}
+	if err != nil {
+		fmt.Printf("Error caching %s: %v\n", url, err)
+	}
+}
+
+// IsRateLimited reports whether err was caused by GitHub throttling.
+func IsRateLimited(err error) bool {
+	var rateLimit *RateLimitError
+	return errors.As(err, &rateLimit)
+}
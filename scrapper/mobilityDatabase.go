@@ -0,0 +1,172 @@
+package scrapper
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MobilityDatabaseURL is the sources CSV of the Mobility Database catalogs.
+const MobilityDatabaseURL = "https://share.mobilitydata.org/catalogs-csv"
+
+// mdbRow gives access to the columns of one CSV record by name.
+type mdbRow struct {
+	record  []string
+	columns map[string]int
+}
+
+func (r mdbRow) get(column string) string {
+	i, ok := r.columns[column]
+	if !ok || i >= len(r.record) {
+		return ""
+	}
+	return strings.TrimSpace(r.record[i])
+}
+
+// ParseMobilityDatabase reads the Mobility Database sources CSV into feed
+// catalog entries. Every active GTFS source becomes one feed, downloaded
+// from the copy the Mobility Database keeps; GTFS-RT sources without
+// authentication are attached to the feeds they reference. Deprecated and
+// inactive sources are left out.
+func ParseMobilityDatabase(r io.Reader) ([]Transitous, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading Mobility Database header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.TrimPrefix(strings.TrimSpace(name), "\ufeff")] = i
+	}
+	for _, column := range []string{"mdb_source_id", "data_type", "urls.latest"} {
+		if _, ok := columns[column]; !ok {
+			return nil, fmt.Errorf("Mobility Database CSV has no column %s", column)
+		}
+	}
+
+	feeds := []Transitous{}
+	byID := map[string]int{}
+	realtime := []mdbRow{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading Mobility Database CSV: %w", err)
+		}
+		row := mdbRow{record: record, columns: columns}
+		switch row.get("status") {
+		case "deprecated", "inactive":
+			continue
+		}
+		switch row.get("data_type") {
+		case "gtfs":
+			feed, ok := mdbFeed(row)
+			if !ok {
+				continue
+			}
+			byID[feed.MdbID] = len(feeds)
+			feeds = append(feeds, feed)
+		case "gtfs_rt":
+			realtime = append(realtime, row)
+		}
+	}
+
+	for _, row := range realtime {
+		url := row.get("urls.direct_download")
+		if url == "" || !mdbOpen(row) {
+			continue
+		}
+		for _, id := range strings.Split(row.get("static_reference"), "|") {
+			if i, ok := byID[strings.TrimSpace(id)]; ok {
+				feeds[i].RealtimeURLs = append(feeds[i].RealtimeURLs, url)
+			}
+		}
+	}
+	return feeds, nil
+}
+
+// mdbFeed maps a GTFS source to a feed. It reports false if the source has
+// no URL that can be downloaded without credentials.
+func mdbFeed(row mdbRow) (Transitous, bool) {
+	id := row.get("mdb_source_id")
+	url := row.get("urls.latest")
+	if url == "" && mdbOpen(row) {
+		url = row.get("urls.direct_download")
+	}
+	if id == "" || url == "" {
+		return Transitous{}, false
+	}
+
+	feed := Transitous{
+		Url:             url,
+		Country:         strings.ToUpper(row.get("location.country_code")),
+		SourceType:      "mobility-database",
+		SourceURL:       row.get("urls.direct_download"),
+		LicenseURL:      row.get("urls.license"),
+		MdbID:           id,
+		Provider:        row.get("provider"),
+		SubdivisionName: row.get("location.subdivision_name"),
+		Municipality:    row.get("location.municipality"),
+		BBox:            mdbBBox(row),
+	}
+	feed.Name = mdbName(feed, row.get("name"))
+	return feed, true
+}
+
+// mdbOpen reports whether a source needs no credentials.
+func mdbOpen(row mdbRow) bool {
+	auth := row.get("urls.authentication_type")
+	return auth == "" || auth == "0"
+}
+
+// mdbName builds a name like the file names of the Transitous feeds,
+// "<country>_<label>.gtfs.zip", which the UI groups by country.
+func mdbName(feed Transitous, name string) string {
+	country := strings.ToLower(feed.Country)
+	if country == "" {
+		country = "mdb"
+	}
+	label := feed.Provider
+	if name != "" && !strings.EqualFold(name, label) {
+		if label != "" {
+			label += " - "
+		}
+		label += name
+	}
+	// The id keeps feeds of the same provider apart.
+	if label == "" {
+		label = "mdb-" + feed.MdbID
+	} else {
+		label += " (mdb-" + feed.MdbID + ")"
+	}
+	return country + "_" + label + ".gtfs.zip"
+}
+
+// mdbBBox returns the bounding box of a source, nil if it is missing or
+// invalid.
+func mdbBBox(row mdbRow) *FeedBBox {
+	values := [4]float64{}
+	for i, column := range []string{
+		"location.bounding_box.minimum_longitude",
+		"location.bounding_box.minimum_latitude",
+		"location.bounding_box.maximum_longitude",
+		"location.bounding_box.maximum_latitude",
+	} {
+		v, err := strconv.ParseFloat(row.get(column), 64)
+		if err != nil {
+			return nil
+		}
+		values[i] = v
+	}
+	bbox := FeedBBox{MinLon: values[0], MinLat: values[1], MaxLon: values[2], MaxLat: values[3]}
+	if bbox.MinLon > bbox.MaxLon || bbox.MinLat > bbox.MaxLat ||
+		bbox.MinLat < -90 || bbox.MaxLat > 90 || bbox.MinLon < -180 || bbox.MaxLon > 180 {
+		return nil
+	}
+	return &bbox
+}
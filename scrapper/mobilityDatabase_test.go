@@ -0,0 +1,72 @@
+package scrapper
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const mdbHeader = "\ufeffmdb_source_id,data_type,provider,name,location.country_code,location.subdivision_name,location.municipality," +
+	"location.bounding_box.minimum_latitude,location.bounding_box.maximum_latitude," +
+	"location.bounding_box.minimum_longitude,location.bounding_box.maximum_longitude," +
+	"urls.direct_download,urls.authentication_type,urls.latest,urls.license,status,static_reference\n"
+
+func TestParseMobilityDatabase(t *testing.T) {
+	csv := mdbHeader +
+		"10,gtfs,Metro,,de,Berlin,Berlin,52.3,52.7,13.0,13.8,https://metro.example/gtfs.zip,0,https://files.mobilitydatabase.org/mdb-10/latest.zip,https://metro.example/license,active,\n" +
+		"11,gtfs,Old,,de,,,,,,,https://old.example/gtfs.zip,0,https://files.mobilitydatabase.org/mdb-11/latest.zip,,deprecated,\n" +
+		"12,gtfs,Closed,,fr,,,,,,,https://closed.example/gtfs.zip,1,,,,\n" +
+		"13,gtfs_rt,Metro,,,,,,,,,https://metro.example/rt,0,,,,10\n" +
+		"14,gtfs_rt,Metro,,,,,,,,,https://metro.example/rt-key,2,,,,10\n" +
+		"15,gtfs,,,,,,60,50,10,20,,,https://files.mobilitydatabase.org/mdb-15/latest.zip,,,\n" +
+		"16,gtfs,Bus Co,Night,us,,,,,,,https://bus.example/gtfs.zip,,,,,\n"
+
+	feeds, err := ParseMobilityDatabase(strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Transitous{
+		{
+			Name:            "de_Metro (mdb-10).gtfs.zip",
+			Url:             "https://files.mobilitydatabase.org/mdb-10/latest.zip",
+			Country:         "DE",
+			SourceType:      "mobility-database",
+			SourceURL:       "https://metro.example/gtfs.zip",
+			LicenseURL:      "https://metro.example/license",
+			RealtimeURLs:    []string{"https://metro.example/rt"},
+			MdbID:           "10",
+			Provider:        "Metro",
+			SubdivisionName: "Berlin",
+			Municipality:    "Berlin",
+			BBox:            &FeedBBox{MinLon: 13.0, MinLat: 52.3, MaxLon: 13.8, MaxLat: 52.7},
+		},
+		{
+			// The bounding box is invalid, minimum above maximum latitude.
+			Name:       "mdb_mdb-15.gtfs.zip",
+			Url:        "https://files.mobilitydatabase.org/mdb-15/latest.zip",
+			SourceType: "mobility-database",
+			MdbID:      "15",
+		},
+		{
+			// Without a copy on the Mobility Database, the open direct
+			// download is used.
+			Name:       "us_Bus Co - Night (mdb-16).gtfs.zip",
+			Url:        "https://bus.example/gtfs.zip",
+			Country:    "US",
+			SourceType: "mobility-database",
+			SourceURL:  "https://bus.example/gtfs.zip",
+			MdbID:      "16",
+			Provider:   "Bus Co",
+		},
+	}
+	if !reflect.DeepEqual(feeds, want) {
+		t.Errorf("ParseMobilityDatabase() =\n%+v\nwant\n%+v", feeds, want)
+	}
+}
+
+func TestParseMobilityDatabaseMissingColumn(t *testing.T) {
+	_, err := ParseMobilityDatabase(strings.NewReader("mdb_source_id,data_type\n1,gtfs\n"))
+	if err == nil || !strings.Contains(err.Error(), "urls.latest") {
+		t.Errorf("ParseMobilityDatabase() error = %v, want the missing urls.latest column", err)
+	}
+}
@@ -3,84 +3,103 @@ package scrapper
 import (
 	"encoding/json"
 	"fmt"
-	"net/http"
+	"log"
 	"os"
-	"strings"
+	"path/filepath"
+	"time"
 )
 
 type Asset struct {
 	Name                 string `json:"name"`
 	Browser_download_url string `json:"browser_download_url"`
-	Os                   string `json:"os"`
-	Arch                 string `json:"arch"`
+	Size                 int64  `json:"size,omitempty"`
+	// Os, Arch, Libc and Variant are parsed from Name, see ParseAssetName.
+	Os      string `json:"os"`
+	Arch    string `json:"arch"`
+	Libc    string `json:"libc,omitempty"`
+	Variant string `json:"variant,omitempty"`
 }
 
-// Release represents the minimal JSON fields from the GitHub API.
+// Release represents the used JSON fields of the GitHub API.
 type Release struct {
-	TagName string  `json:"tag_name"`
-	Name    string  `json:"name"`
-	Assets  []Asset `json:"assets"`
+	TagName     string     `json:"tag_name"`
+	Name        string     `json:"name"`
+	Assets      []Asset    `json:"assets"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	// Prerelease marks releases not meant for production use.
+	Prerelease bool `json:"prerelease"`
+	Draft      bool `json:"draft"`
+	// Body holds the release notes in Markdown.
+	Body string `json:"body,omitempty"`
 }
 
-func (a *Asset) parseOsAndAsset() {
-	fmt.Printf("\"intheparser\": %v\n", "intheparser")
-	isWindows := strings.Contains(a.Name, "windows")
-	if isWindows {
-		a.Os = "windows"
-		a.Arch = "amd64"
-		fmt.Printf("a: %v\n", a)
-		return
-	}
-	isMacos := strings.Contains(a.Name, "macos")
-	isArm := strings.Contains(a.Name, "arm")
-	if isArm {
-		a.Arch = "arm64"
-	} else {
-		a.Arch = "x86"
-	}
-	if isMacos {
-		a.Os = "macos"
-		return
-	} else {
-		a.Os = "linux"
-		return
-	}
-
+// Matches reports whether the asset is built for the given GOOS and GOARCH.
+// Empty values match any.
+func (a Asset) Matches(os, arch string) bool {
+	return (os == "" || a.Os == os) && (arch == "" || a.Arch == arch)
 }
 
-// fetchReleases retrieves releases from GitHub for the given page.
-func FetchReleases(page int) ([]Release, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/motis-project/motis/releases?per_page=100&page=%d", page)
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
+// FilterReleases returns the releases with only the assets matching os and
+// arch, dropping releases without a matching asset.
+func FilterReleases(releases []Release, os, arch string) []Release {
+	result := []Release{}
+	for _, release := range releases {
+		assets := []Asset{}
+		for _, asset := range release.Assets {
+			if asset.Matches(os, arch) {
+				assets = append(assets, asset)
+			}
+		}
+		if len(assets) > 0 {
+			release.Assets = assets
+			result = append(result, release)
+		}
 	}
-	defer resp.Body.Close()
+	return result
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP error: %s", resp.Status)
-	}
+// MotisRepo is the GitHub repository publishing the MOTIS releases.
+const MotisRepo = "motis-project/motis"
+
+// FetchReleases retrieves releases of MotisRepo from GitHub for the given page.
+func FetchReleases(page int) ([]Release, error) {
+	return FetchRepoReleases(MotisRepo, page)
+}
 
+// FetchRepoReleases retrieves releases of a GitHub repository "owner/name"
+// for the given page.
+func FetchRepoReleases(repo string, page int) ([]Release, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/releases?per_page=100&page=%d", repo, page)
 	var releases []Release
-	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
+	if err := GitHub.GetJSON(url, &releases); err != nil {
 		return nil, err
 	}
-
-	for i := range releases {
-		for j := range releases[i].Assets {
-			releases[i].Assets[j].parseOsAndAsset()
-		}
-	}
-
 	return releases, nil
 }
 
+// FetchAll retrieves all releases and caches them in assets/motis.json.
+// While GitHub throttles the client, it returns the cached releases
+// together with the *RateLimitError, or only the error if there is no cache.
 func FetchAll() ([]Release, error) {
+	return FetchRepo(MotisRepo)
+}
 
+// FetchRepo retrieves all releases of a GitHub repository, sorted newest
+// first. Only the releases of MotisRepo are cached, see FetchAll.
+func FetchRepo(repo string) ([]Release, error) {
+	cache := repo == MotisRepo
 	page := 1
 	var allReleases []Release
 	for {
-		releases, err := FetchReleases(page)
+		releases, err := FetchRepoReleases(repo, page)
+		if IsRateLimited(err) && cache {
+			cached, cacheErr := readAssets()
+			if cacheErr != nil {
+				return nil, err
+			}
+			log.Printf("Using cached %s: %v", motisAssetsPath, err)
+			return cached, err
+		}
 		if err != nil {
 			return nil, fmt.Errorf("error fetching page %d: %v", page, err)
 		}
@@ -90,10 +109,12 @@ func FetchAll() ([]Release, error) {
 		allReleases = append(allReleases, releases...)
 		page++
 	}
-	err := writeInToAssets(allReleases)
-	if err != nil {
-		panic(err)
-		return nil, err
+	SortReleases(allReleases)
+	if !cache {
+		return allReleases, nil
+	}
+	if err := writeInToAssets(allReleases); err != nil {
+		log.Printf("Error caching releases in %s: %v", motisAssetsPath, err)
 	}
 	return allReleases, nil
 }
@@ -110,28 +131,30 @@ func PrintRelease(realses []Release) {
 	}
 }
 
+// motisAssetsPath caches the releases of the last successful FetchAll.
+const motisAssetsPath = "assets/motis.json"
+
 func writeInToAssets(releases []Release) error {
 	data, err := json.MarshalIndent(releases, "", "  ")
-	fmt.Printf("data: %v\n", data)
 	if err != nil {
 		return err
 	}
 
 	// Ensure the folder exists
-	folderPath := "assets"
-	if _, err := os.Stat(folderPath); os.IsNotExist(err) {
-		err := os.MkdirAll(folderPath, 0755)
-		if err != nil {
-			return err
-		}
+	if err := os.MkdirAll(filepath.Dir(motisAssetsPath), 0755); err != nil {
+		return err
 	}
 
 	// Write the file
-	filePath := folderPath + "/motis.json"
-	err = os.WriteFile(filePath, data, 0664)
+	return os.WriteFile(motisAssetsPath, data, 0664)
+}
+
+func readAssets() ([]Release, error) {
+	data, err := os.ReadFile(motisAssetsPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	return nil
+	var releases []Release
+	err = json.Unmarshal(data, &releases)
+	return releases, err
 }
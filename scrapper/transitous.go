@@ -1,7 +1,9 @@
 package scrapper
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
 
@@ -11,16 +13,52 @@ import (
 type Transitous struct {
 	Name string
 	Url  string
+
+	// Metadata from the Transitous feed definitions, see EnrichTransitous.
+	Country         string   `json:"country,omitempty"`
+	Subdivision     string   `json:"subdivision,omitempty"`
+	Source          string   `json:"source,omitempty"`
+	SourceType      string   `json:"sourceType,omitempty"`
+	SourceURL       string   `json:"sourceUrl,omitempty"`
+	License         string   `json:"license,omitempty"`
+	LicenseURL      string   `json:"licenseUrl,omitempty"`
+	UpdateFrequency string   `json:"updateFrequency,omitempty"`
+	RealtimeURLs    []string `json:"realtimeUrls,omitempty"`
+	GBFSURLs        []string `json:"gbfsUrls,omitempty"`
+
+	// Metadata from the Mobility Database catalog, see
+	// ParseMobilityDatabase.
+	MdbID           string    `json:"mdbId,omitempty"`
+	Provider        string    `json:"provider,omitempty"`
+	SubdivisionName string    `json:"subdivisionName,omitempty"`
+	Municipality    string    `json:"municipality,omitempty"`
+	BBox            *FeedBBox `json:"bbox,omitempty"`
 }
 
-func GetProcesGTFSLinks() ([]Transitous, error) {
+// FeedBBox is the bounding box of the stops of a feed in degrees. It has
+// the layout of spatial.BBox.
+type FeedBBox struct {
+	MinLon float64 `json:"minLon"`
+	MinLat float64 `json:"minLat"`
+	MaxLon float64 `json:"maxLon"`
+	MaxLat float64 `json:"maxLat"`
+}
+
+// GetProcesGTFSLinks lists the feeds of the Transitous GTFS index with the
+// metadata of their definitions. Cancelling ctx stops all requests.
+func GetProcesGTFSLinks(ctx context.Context) ([]Transitous, error) {
 	url := "https://api.transitous.org/gtfs/"
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
 
 	if err != nil {
-		return nil, fmt.Errorf("HTTP error: %s", resp.Status)
+		return nil, fmt.Errorf("error fetching %s: %v", url, err)
 	}
+	defer resp.Body.Close()
 
 	doc, err := goquery.NewDocumentFromReader(resp.Body)
 
@@ -43,5 +81,14 @@ func GetProcesGTFSLinks() ([]Transitous, error) {
 		}
 	})
 
-	return result, nil
+	metadata, err := FetchTransitousMetadata(ctx)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		log.Printf("Error fetching Transitous metadata: %v", err)
+		metadata = map[string]Transitous{}
+	}
+
+	return EnrichTransitous(result, metadata), nil
 }
@@ -0,0 +1,153 @@
+package scrapper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// TransitousFeedsURL lists the feed definition files of the Transitous project.
+const TransitousFeedsURL = "https://api.github.com/repos/public-transport/transitous/contents/feeds"
+
+// transitousDefinition is one feeds/<region>.json file of Transitous.
+type transitousDefinition struct {
+	Sources []transitousSource `json:"sources"`
+}
+
+type transitousSource struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Spec string `json:"spec"`
+	URL  string `json:"url"`
+	// MdbID is the Mobility Database id of "mobility-database" sources.
+	MdbID json.Number `json:"mdb-id"`
+	// UpdateFrequency is optional and not set by most definitions.
+	UpdateFrequency string `json:"update-frequency"`
+	License         struct {
+		SpdxIdentifier string `json:"spdx-identifier"`
+		URL            string `json:"url"`
+	} `json:"license"`
+	Skip bool `json:"skip"`
+}
+
+// FetchTransitousMetadata downloads all Transitous feed definitions and
+// returns their sources keyed by the feed name used on the GTFS index,
+// i.e. "<region>_<name>". The listing is read through GitHub to share its
+// token, rate limit and cache; the definition files are not API requests.
+func FetchTransitousMetadata(ctx context.Context) (map[string]Transitous, error) {
+	files := []struct {
+		Name        string `json:"name"`
+		DownloadURL string `json:"download_url"`
+	}{}
+	if err := GitHub.GetJSONContext(ctx, TransitousFeedsURL, &files); err != nil {
+		return nil, err
+	}
+
+	result := map[string]Transitous{}
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name, ".json") || file.DownloadURL == "" {
+			continue
+		}
+		definition := transitousDefinition{}
+		if err := getJSON(ctx, file.DownloadURL, &definition); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Printf("Error fetching Transitous definition %s: %v", file.Name, err)
+			continue
+		}
+		region := strings.TrimSuffix(file.Name, ".json")
+		mergeTransitousDefinition(result, region, definition)
+	}
+	return result, nil
+}
+
+// mergeTransitousDefinition adds the sources of one region to feeds.
+// GTFS-RT and GBFS sources are attached to the GTFS feed of the same name.
+func mergeTransitousDefinition(feeds map[string]Transitous, region string, definition transitousDefinition) {
+	country, subdivision := transitousRegion(region)
+
+	for _, source := range definition.Sources {
+		if source.Skip || source.Name == "" {
+			continue
+		}
+		key := region + "_" + source.Name
+		feed := feeds[key]
+		feed.Country = country
+		feed.Subdivision = subdivision
+		feed.Source = source.Name
+
+		switch strings.ToLower(source.Spec) {
+		case "gtfs-rt":
+			if source.URL != "" {
+				feed.RealtimeURLs = append(feed.RealtimeURLs, source.URL)
+			}
+		case "gbfs":
+			if source.URL != "" {
+				feed.GBFSURLs = append(feed.GBFSURLs, source.URL)
+			}
+		default:
+			feed.SourceType = source.Type
+			feed.SourceURL = source.URL
+			feed.MdbID = source.MdbID.String()
+			feed.License = source.License.SpdxIdentifier
+			feed.LicenseURL = source.License.URL
+			feed.UpdateFrequency = source.UpdateFrequency
+		}
+		feeds[key] = feed
+	}
+}
+
+// transitousRegion splits a definition file name like "us-wa" into the
+// ISO 3166-1 country "US" and the subdivision "US-WA".
+func transitousRegion(region string) (string, string) {
+	region = strings.ToUpper(region)
+	country, _, hasSubdivision := strings.Cut(region, "-")
+	if !hasSubdivision {
+		return country, ""
+	}
+	return country, region
+}
+
+// EnrichTransitous copies the metadata onto the feeds of the GTFS index.
+func EnrichTransitous(feeds []Transitous, metadata map[string]Transitous) []Transitous {
+	for i, feed := range feeds {
+		meta, ok := metadata[strings.TrimSuffix(feed.Name, ".gtfs.zip")]
+		if !ok {
+			// The region prefix is known even without a definition.
+			region, _, found := strings.Cut(feed.Name, "_")
+			if found {
+				feeds[i].Country, feeds[i].Subdivision = transitousRegion(region)
+			}
+			continue
+		}
+		meta.Name = feed.Name
+		meta.Url = feed.Url
+		feeds[i] = meta
+	}
+	return feeds
+}
+
+func getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error fetching %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP error: %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,66 @@
+package scrapper
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestMergeAndEnrichTransitous(t *testing.T) {
+	data, err := os.ReadFile("testdata/transitous-de-by.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	definition := transitousDefinition{}
+	if err := json.Unmarshal(data, &definition); err != nil {
+		t.Fatal(err)
+	}
+	metadata := map[string]Transitous{}
+	mergeTransitousDefinition(metadata, "de-by", definition)
+
+	if _, ok := metadata["de-by_broken"]; ok {
+		t.Errorf("skipped source de-by_broken was merged")
+	}
+
+	feeds := EnrichTransitous([]Transitous{
+		{Name: "de-by_mvv.gtfs.zip", Url: "https://api.transitous.org/gtfs/de-by_mvv.gtfs.zip"},
+		{Name: "de-by_vgn.gtfs.zip", Url: "https://api.transitous.org/gtfs/de-by_vgn.gtfs.zip"},
+		{Name: "fr_sncf.gtfs.zip", Url: "https://api.transitous.org/gtfs/fr_sncf.gtfs.zip"},
+	}, metadata)
+
+	want := []Transitous{
+		{
+			Name:            "de-by_mvv.gtfs.zip",
+			Url:             "https://api.transitous.org/gtfs/de-by_mvv.gtfs.zip",
+			Country:         "DE",
+			Subdivision:     "DE-BY",
+			Source:          "mvv",
+			SourceType:      "http",
+			SourceURL:       "https://www.mvv-muenchen.de/gtfs.zip",
+			License:         "CC-BY-4.0",
+			LicenseURL:      "https://www.mvv-muenchen.de/license",
+			UpdateFrequency: "weekly",
+			RealtimeURLs:    []string{"https://realtime.example/mvv"},
+			GBFSURLs:        []string{"https://gbfs.example/mvg-rad/gbfs.json"},
+		},
+		{
+			Name:        "de-by_vgn.gtfs.zip",
+			Url:         "https://api.transitous.org/gtfs/de-by_vgn.gtfs.zip",
+			Country:     "DE",
+			Subdivision: "DE-BY",
+			Source:      "vgn",
+			SourceType:  "mobility-database",
+			MdbID:       "1234",
+		},
+		{
+			// Without a definition only the region prefix is known.
+			Name:    "fr_sncf.gtfs.zip",
+			Url:     "https://api.transitous.org/gtfs/fr_sncf.gtfs.zip",
+			Country: "FR",
+		},
+	}
+	if !reflect.DeepEqual(feeds, want) {
+		t.Errorf("EnrichTransitous() =\n%+v\nwant\n%+v", feeds, want)
+	}
+}
@@ -0,0 +1,147 @@
+package scrapper
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed semantic version tag like v2.6.1 or v2.0.0-rc.1.
+// Missing minor and patch numbers, as in v0.3, are zero.
+type Version struct {
+	Major, Minor, Patch int
+	Pre                 string
+}
+
+// ParseVersion parses a release tag, with or without a leading v.
+func ParseVersion(tag string) (Version, bool) {
+	v := Version{}
+	core := strings.TrimPrefix(strings.TrimSpace(tag), "v")
+	core, _, _ = strings.Cut(core, "+")
+	core, v.Pre, _ = strings.Cut(core, "-")
+
+	parts := strings.Split(core, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return Version{}, false
+	}
+	numbers := [3]int{}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return Version{}, false
+		}
+		numbers[i] = n
+	}
+	v.Major, v.Minor, v.Patch = numbers[0], numbers[1], numbers[2]
+	return v, true
+}
+
+// Compare returns -1, 0 or 1 if v is older, equal or newer than o.
+// Prereleases are older than the release they precede.
+func (v Version) Compare(o Version) int {
+	for _, d := range [3]int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+	switch {
+	case v.Pre == o.Pre:
+		return 0
+	case v.Pre == "":
+		return 1
+	case o.Pre == "":
+		return -1
+	}
+	return comparePrerelease(v.Pre, o.Pre)
+}
+
+// comparePrerelease compares dot separated identifiers, numeric ones
+// numerically and before alphanumeric ones.
+func comparePrerelease(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				return sign(an - bn)
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return sign(len(as) - len(bs))
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// CompareTags compares two release tags by version. Tags that are no
+// version are older than all versions and compared as strings.
+func CompareTags(a, b string) int {
+	va, okA := ParseVersion(a)
+	vb, okB := ParseVersion(b)
+	switch {
+	case okA && okB:
+		return va.Compare(vb)
+	case okA:
+		return 1
+	case okB:
+		return -1
+	}
+	return strings.Compare(a, b)
+}
+
+// SortReleases orders releases newest version first.
+func SortReleases(releases []Release) {
+	sort.SliceStable(releases, func(i, j int) bool {
+		return CompareTags(releases[i].TagName, releases[j].TagName) > 0
+	})
+}
+
+// StableReleases returns the releases that are neither drafts nor
+// prereleases.
+func StableReleases(releases []Release) []Release {
+	result := []Release{}
+	for _, release := range releases {
+		if !release.Prerelease && !release.Draft {
+			result = append(result, release)
+		}
+	}
+	return result
+}
+
+// Changelog returns the releases newer than from up to and including to,
+// newest first. An empty from includes all releases up to to, as does a
+// from that is no version, e.g. a custom build.
+func Changelog(releases []Release, from, to string) []Release {
+	if _, ok := ParseVersion(from); !ok {
+		from = ""
+	}
+	result := []Release{}
+	for _, release := range releases {
+		if CompareTags(release.TagName, to) > 0 {
+			continue
+		}
+		if from != "" && CompareTags(release.TagName, from) <= 0 {
+			continue
+		}
+		result = append(result, release)
+	}
+	SortReleases(result)
+	return result
+}
@@ -0,0 +1,98 @@
+package scrapper
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want Version
+		ok   bool
+	}{
+		{"v2.6.1", Version{2, 6, 1, ""}, true},
+		{"2.6.1", Version{2, 6, 1, ""}, true},
+		{"v0.3", Version{0, 3, 0, ""}, true},
+		{"v2", Version{2, 0, 0, ""}, true},
+		{"v2.0.0-rc.1+build.5", Version{2, 0, 0, "rc.1"}, true},
+		{"v1.2.3.4", Version{}, false},
+		{"v2.x", Version{}, false},
+		{"nightly", Version{}, false},
+		{"", Version{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseVersion(tt.tag)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ParseVersion(%q) = %+v, %v, want %+v, %v", tt.tag, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCompareTags(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"v2.6.1", "2.6.1", 0},
+		{"v2.10.0", "v2.9.0", 1},
+		{"v2.6.10", "v2.6.9", 1},
+		{"v0.3", "v0.3.0", 0},
+		{"v2.0.0-rc.1", "v2.0.0", -1},
+		{"v2.0.0", "v2.0.0-rc.1", 1},
+		{"v2.0.0-rc.10", "v2.0.0-rc.2", 1},
+		{"v2.0.0-alpha", "v2.0.0-beta", -1},
+		{"v2.0.0-1", "v2.0.0-alpha", -1},
+		{"v2.0.0-rc", "v2.0.0-rc.1", -1},
+		{"v1.9.9", "v2.0.0-rc.1", -1},
+		{"nightly", "v0.0.1", -1},
+		{"v0.0.1", "nightly", 1},
+		{"a-build", "b-build", -1},
+	}
+	for _, tt := range tests {
+		if got := CompareTags(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareTags(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func tags(releases []Release) []string {
+	result := []string{}
+	for _, release := range releases {
+		result = append(result, release.TagName)
+	}
+	return result
+}
+
+func TestChangelog(t *testing.T) {
+	releases := []Release{}
+	for _, tag := range []string{"nightly", "v2.6.0-rc.1", "v2.5.0", "v2.7.0", "v2.6.0", "v2.6.1"} {
+		releases = append(releases, Release{TagName: tag})
+	}
+	SortReleases(releases)
+	if got, want := tags(releases), []string{"v2.7.0", "v2.6.1", "v2.6.0", "v2.6.0-rc.1", "v2.5.0", "nightly"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("SortReleases() = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name     string
+		from, to string
+		want     []string
+	}{
+		{"between releases", "v2.6.0", "v2.7.0", []string{"v2.7.0", "v2.6.1"}},
+		{"without from", "", "v2.6.0", []string{"v2.6.0", "v2.6.0-rc.1", "v2.5.0", "nightly"}},
+		{"from a prerelease", "v2.6.0-rc.1", "v2.6.0", []string{"v2.6.0"}},
+		{"from a tag not in the list", "v2.6.0-rc.2", "v2.6.1", []string{"v2.6.1", "v2.6.0"}},
+		{"to a tag not in the list", "v2.5.0", "v2.6.5", []string{"v2.6.1", "v2.6.0", "v2.6.0-rc.1"}},
+		{"up to date", "v2.7.0", "v2.7.0", []string{}},
+		{"from newer than to", "v2.7.0", "v2.6.0", []string{}},
+		{"from a tag that is no version", "custom-1a2b3c4d", "v2.5.0", []string{"v2.5.0", "nightly"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tags(Changelog(releases, tt.from, tt.to)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Changelog(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
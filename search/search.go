@@ -0,0 +1,186 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// DefaultLimit and MaxLimit bound the page size of a search.
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// Field is a searchable text of an item. Matches in fields with a higher
+// weight rank higher.
+type Field struct {
+	Text   string
+	Weight float64
+}
+
+// Result is a matched item with its score.
+type Result[T any] struct {
+	Item  T       `json:"item"`
+	Score float64 `json:"score"`
+}
+
+// Page is one page of ranked results.
+type Page[T any] struct {
+	Query  string      `json:"query"`
+	Total  int         `json:"total"`
+	Offset int         `json:"offset"`
+	Limit  int         `json:"limit"`
+	Items  []Result[T] `json:"items"`
+}
+
+// Search ranks items by how well one of their fields matches query and
+// returns the page starting at offset. Matching ignores case, diacritics
+// and punctuation and tolerates small typos.
+func Search[T any](items []T, query string, fields func(T) []Field, offset, limit int) Page[T] {
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	limit = min(limit, MaxLimit)
+	offset = max(offset, 0)
+
+	page := Page[T]{Query: query, Offset: offset, Limit: limit, Items: []Result[T]{}}
+	q := Normalize(query)
+	if q == "" {
+		return page
+	}
+
+	results := []Result[T]{}
+	for _, item := range items {
+		best := 0.0
+		for _, field := range fields(item) {
+			if score := Score(q, Normalize(field.Text)) * field.Weight; score > best {
+				best = score
+			}
+		}
+		if best > 0 {
+			results = append(results, Result[T]{Item: item, Score: best})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+
+	page.Total = len(results)
+	if offset < len(results) {
+		page.Items = results[offset:min(offset+limit, len(results))]
+	}
+	return page
+}
+
+var stripMarks = transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+
+// foldings covers letters that do not decompose into a base letter.
+var foldings = strings.NewReplacer("ß", "ss", "ø", "o", "æ", "ae", "œ", "oe", "ł", "l", "đ", "d", "ı", "i", "þ", "th")
+
+// Normalize lowercases s, removes diacritics and replaces everything but
+// letters and digits by single spaces.
+func Normalize(s string) string {
+	s = strings.ToLower(s)
+	if folded, _, err := transform.String(stripMarks, s); err == nil {
+		s = folded
+	}
+	s = foldings.Replace(s)
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// Score rates how well the normalized query matches the normalized text,
+// from 0 (no match) to 100 (equal).
+func Score(query, text string) float64 {
+	if query == "" || text == "" {
+		return 0
+	}
+	// Prefer shorter texts among otherwise equal matches. Fuzzy matches
+	// can be longer than the text, which must not earn a bonus.
+	lengthPenalty := min(max(float64(len(text)-len(query))/100, 0), 5)
+
+	switch {
+	case text == query:
+		return 100
+	case strings.HasPrefix(text, query):
+		return 90 - lengthPenalty
+	case strings.Contains(" "+text, " "+query):
+		return 80 - lengthPenalty
+	case strings.Contains(text, query):
+		return 70 - lengthPenalty
+	}
+
+	// Every query word has to match a word of the text, exactly, as prefix
+	// or with a few typos.
+	words := strings.Fields(text)
+	total := 0.0
+	for _, qw := range strings.Fields(query) {
+		best := 0.0
+		for _, w := range words {
+			best = max(best, wordScore(qw, w))
+		}
+		if best == 0 {
+			return 0
+		}
+		total += best
+	}
+	return total/float64(len(strings.Fields(query))) - lengthPenalty
+}
+
+func wordScore(query, word string) float64 {
+	switch {
+	case query == word:
+		return 60
+	case strings.HasPrefix(word, query):
+		return 55
+	case strings.Contains(word, query):
+		return 45
+	}
+
+	rq, rw := []rune(query), []rune(word)
+	allowed := 0
+	switch {
+	case len(rq) >= 8:
+		allowed = 2
+	case len(rq) >= 4:
+		allowed = 1
+	}
+	if allowed == 0 {
+		return 0
+	}
+
+	// Compare against the word and against its prefix of the query length,
+	// so that typos in partially typed words are tolerated.
+	distance := levenshtein(rq, rw)
+	if len(rw) > len(rq) {
+		distance = min(distance, levenshtein(rq, rw[:len(rq)]))
+	}
+	if distance > allowed {
+		return 0
+	}
+	return 40 - float64(distance)*10
+}
+
+func levenshtein(ra, rb []rune) int {
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,81 @@
+package search
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Berlin", "berlin"},
+		{"  BADEN-Württemberg ", "baden wurttemberg"},
+		{"Île-de-France", "ile de france"},
+		{"São Paulo", "sao paulo"},
+		{"Straße", "strasse"},
+		{"Ærø, Øresund", "aero oresund"},
+		{"Łódź", "lodz"},
+		{"de_by.gtfs.zip", "de by gtfs zip"},
+		{"v2.6.1", "v2 6 1"},
+		{"--", ""},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.in); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestScoreOrder(t *testing.T) {
+	// Each text matches the query worse than the one before.
+	query := Normalize("baden")
+	texts := []string{
+		"Baden",
+		"Baden-Württemberg",
+		"Baden-Württemberg und Umgebung",
+		"Lower Baden",
+		"Wiesbaden",
+		"Baaden",
+	}
+	previous := 101.0
+	for _, text := range texts {
+		score := Score(query, Normalize(text))
+		if score <= 0 || score >= previous {
+			t.Errorf("Score(%q, %q) = %v, want between 0 and %v", query, text, score, previous)
+		}
+		previous = score
+	}
+	if score := Score(query, Normalize("Bayern")); score != 0 {
+		t.Errorf("Score(%q, \"Bayern\") = %v, want 0", query, score)
+	}
+}
+
+func TestScoreFuzzyLongerQuery(t *testing.T) {
+	// A typo making the query longer than the text must not earn a length
+	// bonus.
+	if got := Score("berlinn", "berlin"); got != 40-10 {
+		t.Errorf("Score(berlinn, berlin) = %v, want %v", got, 40-10)
+	}
+	if exact, typo := Score("hamburg", "hamburg"), Score("hamburgg", "hamburg"); typo >= exact {
+		t.Errorf("typo score %v not below exact score %v", typo, exact)
+	}
+}
+
+func TestSearchRanking(t *testing.T) {
+	items := []string{"Wiesbaden", "Baden-Württemberg", "Bayern", "Baden"}
+	fields := func(s string) []Field { return []Field{{Text: s, Weight: 1}} }
+
+	page := Search(items, "BADEN", fields, 0, 0)
+	want := []string{"Baden", "Baden-Württemberg", "Wiesbaden"}
+	if page.Total != len(want) || len(page.Items) != len(want) {
+		t.Fatalf("Search() = %+v, want %v", page.Items, want)
+	}
+	for i, result := range page.Items {
+		if result.Item != want[i] {
+			t.Errorf("Items[%d] = %q, want %q", i, result.Item, want[i])
+		}
+	}
+
+	page = Search(items, "baden", fields, 1, 1)
+	if page.Total != 3 || len(page.Items) != 1 || page.Items[0].Item != "Baden-Württemberg" {
+		t.Errorf("second page = %+v (total %d), want Baden-Württemberg of 3", page.Items, page.Total)
+	}
+}
@@ -1,2 +1,8 @@
 echo "🌐 Starting main server..."
-docker-compose up server
\ No newline at end of file
+# out/server.env is written with the first generated config; without it
+# the server keeps the default port 8080.
+if [ -f out/server.env ]; then
+  docker-compose --env-file out/server.env up server
+else
+  docker-compose up server
+fi
@@ -0,0 +1,282 @@
+package spatial
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"maxiputz/motisConfigServer/scrapper"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// cellSize is the edge length in degrees of the grid cells of an Index.
+const cellSize = 5.0
+
+// Point is a [lon, lat] coordinate as used by GeoJSON.
+type Point = [2]float64
+
+// polygon is an outer ring followed by optional holes.
+type polygon [][]Point
+
+// BBox is a bounding box in degrees.
+type BBox struct {
+	MinLon float64 `json:"minLon"`
+	MinLat float64 `json:"minLat"`
+	MaxLon float64 `json:"maxLon"`
+	MaxLat float64 `json:"maxLat"`
+}
+
+// ParseBBox parses "minLon,minLat,maxLon,maxLat".
+func ParseBBox(value string) (BBox, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return BBox{}, fmt.Errorf("bbox must be minLon,minLat,maxLon,maxLat")
+	}
+	values := [4]float64{}
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return BBox{}, fmt.Errorf("invalid bbox value %q", part)
+		}
+		values[i] = v
+	}
+	bbox := BBox{MinLon: values[0], MinLat: values[1], MaxLon: values[2], MaxLat: values[3]}
+	if bbox.MinLon > bbox.MaxLon || bbox.MinLat > bbox.MaxLat {
+		return BBox{}, fmt.Errorf("bbox minimum must not be larger than maximum")
+	}
+	if bbox.MinLat < -90 || bbox.MaxLat > 90 || bbox.MinLon < -180 || bbox.MaxLon > 180 {
+		return BBox{}, fmt.Errorf("bbox out of range")
+	}
+	return bbox, nil
+}
+
+func (b BBox) contains(p Point) bool {
+	return p[0] >= b.MinLon && p[0] <= b.MaxLon && p[1] >= b.MinLat && p[1] <= b.MaxLat
+}
+
+func (b BBox) containsBBox(o BBox) bool {
+	return o.MinLon >= b.MinLon && o.MaxLon <= b.MaxLon && o.MinLat >= b.MinLat && o.MaxLat <= b.MaxLat
+}
+
+func (b BBox) corners() []Point {
+	return []Point{{b.MinLon, b.MinLat}, {b.MaxLon, b.MinLat}, {b.MaxLon, b.MaxLat}, {b.MinLon, b.MaxLat}}
+}
+
+// Match is a region found by a lookup. Area is the planar area of the
+// region in square degrees and only meant for ranking.
+type Match struct {
+	Region scrapper.RegionSummary `json:"region"`
+	Area   float64                `json:"area"`
+}
+
+type entry struct {
+	region   scrapper.RegionSummary
+	polygons []polygon
+	bbox     BBox
+	area     float64
+}
+
+type cell struct{ x, y int }
+
+// Index answers point and bounding box queries over region polygons.
+// Regions are bucketed into a grid by their bounding box.
+type Index struct {
+	entries []entry
+	grid    map[cell][]int
+}
+
+// Build creates an index over all regions of tree that have a geometry.
+func Build(tree scrapper.Region) *Index {
+	idx := &Index{grid: map[cell][]int{}}
+
+	var add func(region scrapper.Region)
+	add = func(region scrapper.Region) {
+		for _, child := range region.Children {
+			add(child)
+		}
+		if region.Geometry == nil {
+			return
+		}
+		raw, err := region.Geometry.Polygons()
+		if err != nil {
+			log.Printf("Skipping geometry of %s: %v", region.RegionName, err)
+			return
+		}
+
+		e := entry{region: region.Summary(), bbox: BBox{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}}
+		for _, rings := range raw {
+			p := polygon(rings)
+			if len(p) == 0 || len(p[0]) < 3 {
+				continue
+			}
+			for _, pt := range p[0] {
+				e.bbox.MinLon = math.Min(e.bbox.MinLon, pt[0])
+				e.bbox.MinLat = math.Min(e.bbox.MinLat, pt[1])
+				e.bbox.MaxLon = math.Max(e.bbox.MaxLon, pt[0])
+				e.bbox.MaxLat = math.Max(e.bbox.MaxLat, pt[1])
+			}
+			e.area += p.area()
+			e.polygons = append(e.polygons, p)
+		}
+		if len(e.polygons) == 0 {
+			return
+		}
+
+		id := len(idx.entries)
+		idx.entries = append(idx.entries, e)
+		for _, c := range cellsOf(e.bbox) {
+			idx.grid[c] = append(idx.grid[c], id)
+		}
+	}
+	add(tree)
+
+	return idx
+}
+
+// Len returns the number of indexed regions.
+func (idx *Index) Len() int {
+	return len(idx.entries)
+}
+
+func cellOf(p Point) cell {
+	return cell{int(math.Floor(p[0] / cellSize)), int(math.Floor(p[1] / cellSize))}
+}
+
+func cellsOf(b BBox) []cell {
+	lo, hi := cellOf(Point{b.MinLon, b.MinLat}), cellOf(Point{b.MaxLon, b.MaxLat})
+	cells := []cell{}
+	for x := lo.x; x <= hi.x; x++ {
+		for y := lo.y; y <= hi.y; y++ {
+			cells = append(cells, cell{x, y})
+		}
+	}
+	return cells
+}
+
+// Lookup returns the regions containing the point, smallest first.
+func (idx *Index) Lookup(lat, lon float64) []Match {
+	p := Point{lon, lat}
+	return idx.collect(idx.grid[cellOf(p)], func(e entry) bool {
+		if !e.bbox.contains(p) {
+			return false
+		}
+		for _, poly := range e.polygons {
+			if poly.contains(p) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Cover returns the regions fully covering the bounding box, smallest first.
+func (idx *Index) Cover(b BBox) []Match {
+	// Every covering region contains the lower left corner.
+	candidates := idx.grid[cellOf(Point{b.MinLon, b.MinLat})]
+	return idx.collect(candidates, func(e entry) bool {
+		if !e.bbox.containsBBox(b) {
+			return false
+		}
+		for _, poly := range e.polygons {
+			if poly.coversBBox(b) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func (idx *Index) collect(candidates []int, match func(entry) bool) []Match {
+	result := []Match{}
+	for _, id := range candidates {
+		e := idx.entries[id]
+		if match(e) {
+			result = append(result, Match{Region: e.region, Area: e.area})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Area < result[j].Area })
+	return result
+}
+
+// area returns the area of the outer ring minus the holes.
+func (p polygon) area() float64 {
+	area := ringArea(p[0])
+	for _, hole := range p[1:] {
+		area -= ringArea(hole)
+	}
+	return area
+}
+
+func ringArea(ring []Point) float64 {
+	sum := 0.0
+	for i := range ring {
+		a, b := ring[i], ring[(i+1)%len(ring)]
+		sum += a[0]*b[1] - b[0]*a[1]
+	}
+	return math.Abs(sum) / 2
+}
+
+// contains reports whether p lies inside the outer ring and in no hole.
+func (p polygon) contains(pt Point) bool {
+	if !ringContains(p[0], pt) {
+		return false
+	}
+	for _, hole := range p[1:] {
+		if ringContains(hole, pt) {
+			return false
+		}
+	}
+	return true
+}
+
+// ringContains is the even-odd ray casting test. Points on a left or
+// bottom edge are inside and on a right or top edge outside, so a point on
+// a shared border belongs to one of the two neighbours.
+func ringContains(ring []Point, pt Point) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a[1] > pt[1]) != (b[1] > pt[1]) &&
+			pt[0] < (b[0]-a[0])*(pt[1]-a[1])/(b[1]-a[1])+a[0] {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// coversBBox reports whether the box lies completely inside the polygon:
+// all corners are inside and no ring enters the box.
+func (p polygon) coversBBox(b BBox) bool {
+	for _, corner := range b.corners() {
+		if !p.contains(corner) {
+			return false
+		}
+	}
+	corners := b.corners()
+	for _, ring := range p {
+		for i := range ring {
+			a, c := ring[i], ring[(i+1)%len(ring)]
+			if a[0] > b.MinLon && a[0] < b.MaxLon && a[1] > b.MinLat && a[1] < b.MaxLat {
+				return false
+			}
+			for k := range corners {
+				if segmentsCross(a, c, corners[k], corners[(k+1)%4]) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// segmentsCross reports whether the segments a-b and c-d properly intersect.
+func segmentsCross(a, b, c, d Point) bool {
+	orient := func(p, q, r Point) float64 {
+		return (q[0]-p[0])*(r[1]-p[1]) - (q[1]-p[1])*(r[0]-p[0])
+	}
+	d1, d2 := orient(c, d, a), orient(c, d, b)
+	d3, d4 := orient(a, b, c), orient(a, b, d)
+	return ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) &&
+		((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0))
+}
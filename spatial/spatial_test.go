@@ -0,0 +1,143 @@
+package spatial
+
+import (
+	"encoding/json"
+	"maxiputz/motisConfigServer/scrapper"
+	"testing"
+)
+
+var square = []Point{{0, 0}, {10, 0}, {10, 10}, {0, 10}}
+
+// notched is a U shape open at the top between lon 4 and 6.
+var notched = polygon{{{0, 0}, {10, 0}, {10, 10}, {6, 10}, {6, 4}, {4, 4}, {4, 10}, {0, 10}}}
+
+// holed is the square with a hole between 4 and 6.
+var holed = polygon{square, {{4, 4}, {6, 4}, {6, 6}, {4, 6}}}
+
+func TestRingContains(t *testing.T) {
+	tests := []struct {
+		name string
+		pt   Point
+		want bool
+	}{
+		{"inside", Point{5, 5}, true},
+		{"outside", Point{15, 5}, false},
+		{"beside the ring in its latitude band", Point{-1, 5}, false},
+		{"above the ring", Point{5, 11}, false},
+		// Points on the left and bottom edges are inside and on the
+		// right and top edges outside, so a point on the border of two
+		// neighbouring regions belongs to exactly one of them.
+		{"on the left edge", Point{0, 5}, true},
+		{"on the bottom edge", Point{5, 0}, true},
+		{"on the right edge", Point{10, 5}, false},
+		{"on the top edge", Point{5, 10}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ringContains(square, tt.pt); got != tt.want {
+				t.Errorf("ringContains(%v) = %v, want %v", tt.pt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolygonContains(t *testing.T) {
+	tests := []struct {
+		name string
+		poly polygon
+		pt   Point
+		want bool
+	}{
+		{"outside the hole", holed, Point{2, 2}, true},
+		{"inside the hole", holed, Point{5, 5}, false},
+		{"outside the polygon", holed, Point{12, 5}, false},
+		{"in the arm of a concave ring", notched, Point{2, 8}, true},
+		{"in the notch of a concave ring", notched, Point{5, 8}, false},
+		{"below the notch", notched, Point{5, 2}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.poly.contains(tt.pt); got != tt.want {
+				t.Errorf("contains(%v) = %v, want %v", tt.pt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoversBBox(t *testing.T) {
+	tests := []struct {
+		name string
+		poly polygon
+		bbox BBox
+		want bool
+	}{
+		{"inside", polygon{square}, BBox{1, 1, 3, 3}, true},
+		{"crossing the boundary", polygon{square}, BBox{8, 8, 12, 12}, false},
+		{"larger than the polygon", polygon{square}, BBox{-1, -1, 11, 11}, false},
+		{"beside the hole", holed, BBox{1, 1, 3, 3}, true},
+		{"around the hole", holed, BBox{3, 3, 7, 7}, false},
+		{"below the notch", notched, BBox{1, 1, 9, 3}, true},
+		// All corners lie in the arms, but the notch crosses the box.
+		{"across the notch", notched, BBox{2, 6, 8, 8}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.poly.coversBBox(tt.bbox); got != tt.want {
+				t.Errorf("coversBBox(%+v) = %v, want %v", tt.bbox, got, tt.want)
+			}
+		})
+	}
+}
+
+func region(id, kind string, coordinates any) scrapper.Region {
+	data, _ := json.Marshal(coordinates)
+	return scrapper.Region{ID: id, RegionName: id, Geometry: &scrapper.Geometry{Type: kind, Coordinates: data}}
+}
+
+func TestIndexMultiPolygon(t *testing.T) {
+	islands := [][][]Point{
+		{{{0, 0}, {2, 0}, {2, 2}, {0, 2}}},
+		{{{10, 10}, {12, 10}, {12, 12}, {10, 12}}},
+	}
+	mainland := [][]Point{{{-5, -5}, {20, -5}, {20, 20}, {-5, 20}}}
+	idx := Build(scrapper.Region{Children: []scrapper.Region{
+		region("mainland", "Polygon", mainland),
+		region("islands", "MultiPolygon", islands),
+	}})
+	if idx.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", idx.Len())
+	}
+
+	tests := []struct {
+		name     string
+		lat, lon float64
+		want     []string
+	}{
+		{"first island", 1, 1, []string{"islands", "mainland"}},
+		{"second island", 11, 11, []string{"islands", "mainland"}},
+		{"between the islands", 5, 5, []string{"mainland"}},
+		{"outside", 30, 30, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := idx.Lookup(tt.lat, tt.lon)
+			got := []string{}
+			for _, m := range matches {
+				got = append(got, m.Region.ID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Lookup(%v, %v) = %v, want %v", tt.lat, tt.lon, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Lookup(%v, %v) = %v, want %v", tt.lat, tt.lon, got, tt.want)
+				}
+			}
+		})
+	}
+
+	// The bounding box of the islands covers the gap between them.
+	if matches := idx.Cover(BBox{1, 1, 11, 11}); len(matches) != 1 || matches[0].Region.ID != "mainland" {
+		t.Errorf("Cover() across the islands = %+v, want only mainland", matches)
+	}
+}
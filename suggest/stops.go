@@ -0,0 +1,88 @@
+package suggest
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"maxiputz/motisConfigServer/spatial"
+	"strconv"
+	"strings"
+)
+
+// StopsBBox returns the bounding box of the stops in the stops.txt of a
+// GTFS zip file. Stops without or with null island coordinates are skipped.
+func StopsBBox(gtfsPath string) (spatial.BBox, error) {
+	archive, err := zip.OpenReader(gtfsPath)
+	if err != nil {
+		return spatial.BBox{}, err
+	}
+	defer archive.Close()
+
+	for _, file := range archive.File {
+		if file.Name != "stops.txt" {
+			continue
+		}
+		f, err := file.Open()
+		if err != nil {
+			return spatial.BBox{}, err
+		}
+		defer f.Close()
+		return readStops(f)
+	}
+	return spatial.BBox{}, fmt.Errorf("%s has no stops.txt", gtfsPath)
+}
+
+func readStops(r io.Reader) (spatial.BBox, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return spatial.BBox{}, fmt.Errorf("error reading stops.txt header: %w", err)
+	}
+	latCol, lonCol := -1, -1
+	for i, column := range header {
+		switch strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")) {
+		case "stop_lat":
+			latCol = i
+		case "stop_lon":
+			lonCol = i
+		}
+	}
+	if latCol < 0 || lonCol < 0 {
+		return spatial.BBox{}, fmt.Errorf("stops.txt has no stop_lat or stop_lon column")
+	}
+
+	bbox := spatial.BBox{MinLon: math.Inf(1), MinLat: math.Inf(1), MaxLon: math.Inf(-1), MaxLat: math.Inf(-1)}
+	stops := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return spatial.BBox{}, fmt.Errorf("error reading stops.txt: %w", err)
+		}
+		if latCol >= len(record) || lonCol >= len(record) {
+			continue
+		}
+		lat, errLat := strconv.ParseFloat(strings.TrimSpace(record[latCol]), 64)
+		lon, errLon := strconv.ParseFloat(strings.TrimSpace(record[lonCol]), 64)
+		if errLat != nil || errLon != nil || (lat == 0 && lon == 0) ||
+			lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+			continue
+		}
+		bbox.MinLon = math.Min(bbox.MinLon, lon)
+		bbox.MinLat = math.Min(bbox.MinLat, lat)
+		bbox.MaxLon = math.Max(bbox.MaxLon, lon)
+		bbox.MaxLat = math.Max(bbox.MaxLat, lat)
+		stops++
+	}
+	if stops == 0 {
+		return spatial.BBox{}, fmt.Errorf("stops.txt has no stops with coordinates")
+	}
+	return bbox, nil
+}
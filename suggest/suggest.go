@@ -0,0 +1,216 @@
+package suggest
+
+import (
+	"maxiputz/motisConfigServer/scrapper"
+	"maxiputz/motisConfigServer/search"
+	"maxiputz/motisConfigServer/spatial"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// Method tells how the regions covering a feed were found, from the most
+// to the least reliable.
+type Method string
+
+const (
+	MethodStops       Method = "stops"
+	MethodBBox        Method = "bbox"
+	MethodSubdivision Method = "iso3166-2"
+	MethodCountry     Method = "iso3166-1"
+	MethodName        Method = "name"
+)
+
+// Feed is a selected GTFS feed.
+type Feed struct {
+	scrapper.Transitous
+	// Stops is the bounding box of the stops, nil if the feed has not been
+	// downloaded yet.
+	Stops *spatial.BBox
+}
+
+// FeedMatch tells how the regions covering one feed were found.
+type FeedMatch struct {
+	Name   string `json:"name"`
+	Method Method `json:"method,omitempty"`
+	// Regions is the number of regions covering the feed.
+	Regions int `json:"regions"`
+}
+
+// Candidate is a region covering every resolved feed.
+type Candidate struct {
+	Region scrapper.RegionSummary `json:"region"`
+	// Depth is the level of the region in the tree, continents are 1.
+	Depth int `json:"depth"`
+}
+
+// Suggestion is the result of Suggest.
+type Suggestion struct {
+	Candidates []Candidate `json:"candidates"`
+	Feeds      []FeedMatch `json:"feeds"`
+	// Unresolved lists the feeds no region could be found for. They are
+	// ignored for the candidates.
+	Unresolved []string `json:"unresolved"`
+}
+
+type node struct {
+	region    scrapper.RegionSummary
+	depth     int
+	ancestors []int
+}
+
+// Suggest returns the Geofabrik regions covering all feeds, the most
+// specific first. Stop coordinates are matched against index, which may be
+// nil; otherwise the ISO codes of the feed and finally its country name are
+// compared with the regions of tree.
+func Suggest(tree scrapper.Region, index *spatial.Index, feeds []Feed) Suggestion {
+	nodes := flatten(tree)
+	byKey := map[string]int{}
+	for i, n := range nodes {
+		byKey[n.region.ID] = i
+	}
+
+	result := Suggestion{Candidates: []Candidate{}, Feeds: []FeedMatch{}, Unresolved: []string{}}
+	var common map[int]bool
+	for _, feed := range feeds {
+		covering, method := cover(nodes, byKey, index, feed)
+		result.Feeds = append(result.Feeds, FeedMatch{Name: feed.Name, Method: method, Regions: len(covering)})
+		if len(covering) == 0 {
+			result.Unresolved = append(result.Unresolved, feed.Name)
+			continue
+		}
+		if common == nil {
+			common = covering
+			continue
+		}
+		for i := range common {
+			if !covering[i] {
+				delete(common, i)
+			}
+		}
+	}
+
+	for i := range common {
+		result.Candidates = append(result.Candidates, Candidate{Region: nodes[i].region, Depth: nodes[i].depth})
+	}
+	sort.Slice(result.Candidates, func(i, j int) bool {
+		a, b := result.Candidates[i], result.Candidates[j]
+		if a.Depth != b.Depth {
+			return a.Depth > b.Depth
+		}
+		if a.Region.Size != b.Region.Size && a.Region.Size > 0 && b.Region.Size > 0 {
+			return a.Region.Size < b.Region.Size
+		}
+		return a.Region.RegionName < b.Region.RegionName
+	})
+	return result
+}
+
+// flatten lists all regions below tree with their ancestors. Parent is only
+// set by the Geofabrik index, so ancestors are taken from the tree itself.
+func flatten(tree scrapper.Region) []node {
+	nodes := []node{}
+	var walk func(region scrapper.Region, depth int, ancestors []int)
+	walk = func(region scrapper.Region, depth int, ancestors []int) {
+		for _, child := range region.Children {
+			id := len(nodes)
+			nodes = append(nodes, node{region: child.Summary(), depth: depth, ancestors: ancestors})
+			walk(child, depth+1, append(append([]int{}, ancestors...), id))
+		}
+	}
+	walk(tree, 1, nil)
+	return nodes
+}
+
+// cover returns the regions containing the whole feed using the most
+// reliable method available.
+func cover(nodes []node, byKey map[string]int, index *spatial.Index, feed Feed) (map[int]bool, Method) {
+	if feed.Stops != nil && index != nil {
+		if covering := coverBBox(byKey, index, *feed.Stops); len(covering) > 0 {
+			return covering, MethodStops
+		}
+	}
+	// The catalog knows the stops area of some feeds not downloaded yet.
+	if feed.BBox != nil && index != nil {
+		if covering := coverBBox(byKey, index, spatial.BBox(*feed.BBox)); len(covering) > 0 {
+			return covering, MethodBBox
+		}
+	}
+
+	if feed.Subdivision != "" {
+		if covering := withAncestors(nodes, func(n node) bool {
+			return containsFold(n.region.ISO3166_2, feed.Subdivision)
+		}); len(covering) > 0 {
+			return covering, MethodSubdivision
+		}
+	}
+
+	if feed.Country == "" {
+		return nil, ""
+	}
+	if covering := withAncestors(nodes, func(n node) bool {
+		return containsFold(n.region.ISO3166_1, feed.Country)
+	}); len(covering) > 0 {
+		return covering, MethodCountry
+	}
+
+	// Regions crawled from the HTML pages have no ISO codes. Countries are
+	// on the second level, which keeps e.g. the US state Georgia out.
+	name := countryName(feed.Country)
+	if name == "" {
+		return nil, ""
+	}
+	if covering := withAncestors(nodes, func(n node) bool {
+		return n.depth == 2 && search.Normalize(n.region.RegionName) == name
+	}); len(covering) > 0 {
+		return covering, MethodName
+	}
+	return nil, ""
+}
+
+// coverBBox returns the regions containing the whole bbox.
+func coverBBox(byKey map[string]int, index *spatial.Index, bbox spatial.BBox) map[int]bool {
+	covering := map[int]bool{}
+	for _, match := range index.Cover(bbox) {
+		if i, ok := byKey[match.Region.ID]; ok {
+			covering[i] = true
+		}
+	}
+	return covering
+}
+
+// withAncestors returns the regions matching match and all regions
+// containing them.
+func withAncestors(nodes []node, match func(node) bool) map[int]bool {
+	result := map[int]bool{}
+	for i, n := range nodes {
+		if !match(n) {
+			continue
+		}
+		result[i] = true
+		for _, a := range n.ancestors {
+			result[a] = true
+		}
+	}
+	return result
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// countryName returns the normalized English name of an ISO 3166-1 code.
+func countryName(code string) string {
+	region, err := language.ParseRegion(code)
+	if err != nil {
+		return ""
+	}
+	return search.Normalize(display.English.Regions().Name(region))
+}
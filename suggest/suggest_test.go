@@ -0,0 +1,127 @@
+package suggest
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"maxiputz/motisConfigServer/scrapper"
+	"maxiputz/motisConfigServer/spatial"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeGTFS zips stops.txt into a GTFS file in a temporary directory.
+func writeGTFS(t *testing.T, stops []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "feed.gtfs.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	archive := zip.NewWriter(f)
+	w, err := archive.Create("stops.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(stops); err != nil {
+		t.Fatal(err)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// box is a region with a rectangular geometry.
+func box(id string, minLon, minLat, maxLon, maxLat float64, children ...scrapper.Region) scrapper.Region {
+	coordinates, _ := json.Marshal([][][2]float64{{{minLon, minLat}, {maxLon, minLat}, {maxLon, maxLat}, {minLon, maxLat}}})
+	return scrapper.Region{
+		ID:         id,
+		RegionName: id,
+		Children:   children,
+		Geometry:   &scrapper.Geometry{Type: "Polygon", Coordinates: coordinates},
+	}
+}
+
+func TestSuggestFromStops(t *testing.T) {
+	tree := scrapper.Region{Children: []scrapper.Region{
+		box("europe", -10, 35, 40, 70,
+			box("germany", 5, 47, 15, 55,
+				box("bayern", 9, 47, 14, 50.5),
+				box("berlin", 13, 52.3, 13.8, 52.7),
+			),
+		),
+	}}
+	index := spatial.Build(tree)
+
+	stops, err := os.ReadFile(filepath.Join("testdata", "stops.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	munich, err := StopsBBox(writeGTFS(t, stops))
+	if err != nil {
+		t.Fatalf("StopsBBox() error = %v", err)
+	}
+	// The stops without coordinates and at null island are skipped.
+	if want := (spatial.BBox{MinLon: 11.558338, MinLat: 48.140228, MaxLon: 11.785224, MaxLat: 48.395363}); munich != want {
+		t.Fatalf("StopsBBox() = %+v, want %+v", munich, want)
+	}
+
+	// A feed of stops in Berlin and Munich, which only Germany covers.
+	national, err := StopsBBox(writeGTFS(t, []byte("stop_id,stop_lat,stop_lon\na,52.52,13.40\nb,48.14,11.56\n")))
+	if err != nil {
+		t.Fatalf("StopsBBox() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		feeds []Feed
+		want  []string
+	}{
+		{
+			name:  "regional feed",
+			feeds: []Feed{{Transitous: scrapper.Transitous{Name: "de_mvv.gtfs.zip"}, Stops: &munich}},
+			want:  []string{"bayern", "germany", "europe"},
+		},
+		{
+			name:  "national feed",
+			feeds: []Feed{{Transitous: scrapper.Transitous{Name: "de_db.gtfs.zip"}, Stops: &national}},
+			want:  []string{"germany", "europe"},
+		},
+		{
+			name: "both feeds",
+			feeds: []Feed{
+				{Transitous: scrapper.Transitous{Name: "de_mvv.gtfs.zip"}, Stops: &munich},
+				{Transitous: scrapper.Transitous{Name: "de_db.gtfs.zip"}, Stops: &national},
+			},
+			want: []string{"germany", "europe"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suggestion := Suggest(tree, index, tt.feeds)
+			got := []string{}
+			for _, candidate := range suggestion.Candidates {
+				got = append(got, candidate.Region.ID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("candidates = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("candidates = %v, want %v", got, tt.want)
+					break
+				}
+			}
+			for _, match := range suggestion.Feeds {
+				if match.Method != MethodStops {
+					t.Errorf("feed %s matched by %q, want %q", match.Name, match.Method, MethodStops)
+				}
+			}
+			if len(suggestion.Unresolved) != 0 {
+				t.Errorf("unresolved = %v, want none", suggestion.Unresolved)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package versions
+
+import (
+	"context"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// versionTimeout bounds how long motis --version may run.
+const versionTimeout = 10 * time.Second
+
+// Report is the result of a preflight check of a motis binary.
+type Report struct {
+	Binary string `json:"binary"`
+	// OS and Arch are read from the executable header, as GOOS and GOARCH.
+	OS         string `json:"os,omitempty"`
+	Arch       string `json:"arch,omitempty"`
+	Executable bool   `json:"executable"`
+	// Version is the first line printed by motis --version.
+	Version   string    `json:"version,omitempty"`
+	Problems  []string  `json:"problems"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// OK reports whether the binary can be run on this host.
+func (r Report) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// Preflight checks that binary is built for this host, is executable and
+// runs, before config generation or import depend on it.
+func Preflight(binary string) Report {
+	report := Report{Binary: binary, Problems: []string{}, CheckedAt: time.Now()}
+
+	info, err := os.Stat(binary)
+	if err != nil {
+		report.Problems = append(report.Problems, fmt.Sprintf("binary not found: %v", err))
+		return report
+	}
+	report.Executable = runtime.GOOS == "windows" || info.Mode()&0111 != 0
+	if !report.Executable {
+		report.Problems = append(report.Problems, "binary is not executable")
+	}
+
+	goos, goarch, err := readHeader(binary)
+	if err != nil {
+		report.Problems = append(report.Problems, err.Error())
+		return report
+	}
+	report.OS = goos
+	report.Arch = strings.Join(goarch, ",")
+	if goos != runtime.GOOS || !runsOn(goarch) {
+		report.Problems = append(report.Problems, fmt.Sprintf("binary is built for %s/%s, this host is %s/%s", goos, report.Arch, runtime.GOOS, runtime.GOARCH))
+	}
+	if !report.OK() {
+		return report
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), versionTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, binary, "--version").CombinedOutput()
+	if err != nil {
+		report.Problems = append(report.Problems, fmt.Sprintf("motis --version failed: %v: %s", err, strings.TrimSpace(string(output))))
+		return report
+	}
+	report.Version, _, _ = strings.Cut(strings.TrimSpace(string(output)), "\n")
+	return report
+}
+
+// runsOn reports whether one of the architectures of a binary runs on the
+// host. Apple silicon also runs amd64 binaries through Rosetta.
+func runsOn(archs []string) bool {
+	for _, arch := range archs {
+		if arch == runtime.GOARCH || (runtime.GOOS == "darwin" && runtime.GOARCH == "arm64" && arch == "amd64") {
+			return true
+		}
+	}
+	return false
+}
+
+// readHeader returns the GOOS and the GOARCH values of an ELF, Mach-O or
+// PE executable. Universal Mach-O binaries have several architectures.
+func readHeader(binary string) (string, []string, error) {
+	if f, err := elf.Open(binary); err == nil {
+		defer f.Close()
+		goos := "linux"
+		if f.OSABI == elf.ELFOSABI_FREEBSD {
+			goos = "freebsd"
+		}
+		return goos, []string{elfArch(f.Machine)}, nil
+	}
+	if f, err := macho.Open(binary); err == nil {
+		defer f.Close()
+		return "darwin", []string{machoArch(f.Cpu)}, nil
+	}
+	if f, err := macho.OpenFat(binary); err == nil {
+		defer f.Close()
+		archs := []string{}
+		for _, arch := range f.Arches {
+			archs = append(archs, machoArch(arch.Cpu))
+		}
+		return "darwin", archs, nil
+	}
+	if f, err := pe.Open(binary); err == nil {
+		defer f.Close()
+		return "windows", []string{peArch(f.Machine)}, nil
+	}
+	return "", nil, fmt.Errorf("%s is no ELF, Mach-O or PE executable", filepath.Base(binary))
+}
+
+func elfArch(machine elf.Machine) string {
+	switch machine {
+	case elf.EM_X86_64:
+		return "amd64"
+	case elf.EM_AARCH64:
+		return "arm64"
+	case elf.EM_ARM:
+		return "arm"
+	case elf.EM_386:
+		return "386"
+	}
+	return strings.ToLower(strings.TrimPrefix(machine.String(), "EM_"))
+}
+
+func machoArch(cpu macho.Cpu) string {
+	switch cpu {
+	case macho.CpuAmd64:
+		return "amd64"
+	case macho.CpuArm64:
+		return "arm64"
+	case macho.Cpu386:
+		return "386"
+	}
+	return strings.ToLower(strings.TrimPrefix(cpu.String(), "Cpu"))
+}
+
+func peArch(machine uint16) string {
+	switch machine {
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		return "amd64"
+	case pe.IMAGE_FILE_MACHINE_ARM64:
+		return "arm64"
+	case pe.IMAGE_FILE_MACHINE_I386:
+		return "386"
+	}
+	return fmt.Sprintf("pe-machine-%#x", machine)
+}
+
+// Preflight checks the binary of the installed version tag and records
+// the report in its version.json.
+func (m *Manager) Preflight(tag string) (Report, error) {
+	version, err := m.Get(tag)
+	if err != nil {
+		return Report{}, err
+	}
+	report := Preflight(m.binary(tag))
+	version.Preflight = &report
+	version.Active = false
+	data, err := json.MarshalIndent(version, "", "  ")
+	if err != nil {
+		return report, err
+	}
+	return report, os.WriteFile(filepath.Join(m.path(tag), metadataFile), data, 0644)
+}
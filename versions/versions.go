@@ -0,0 +1,291 @@
+package versions
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"maxiputz/motisConfigServer/download"
+	"maxiputz/motisConfigServer/scrapper"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// currentLink is the name of the symlink pointing at the active version.
+const currentLink = "current"
+
+// metadataFile describes an installed version inside its directory.
+const metadataFile = "version.json"
+
+// Version is an installed MOTIS release.
+type Version struct {
+	Tag         string    `json:"tag"`
+	Asset       string    `json:"asset,omitempty"`
+	URL         string    `json:"url,omitempty"`
+	InstalledAt time.Time `json:"installedAt"`
+	Active      bool      `json:"active"`
+	// Preflight is the last check of the binary, see Manager.Preflight.
+	Preflight *Report `json:"preflight,omitempty"`
+}
+
+// Manager keeps MOTIS releases side by side in Dir, one subdirectory per
+// release tag, and a "current" symlink naming the active one. The entries
+// of the active version are linked into WorkDir, so ./motis in the
+// workspace always runs the active version:
+//
+//	out/motis -> versions/current/motis
+//	out/versions/current -> v2.6.1
+//	out/versions/v2.6.1/motis
+type Manager struct {
+	WorkDir string
+	Dir     string
+}
+
+// NewManager manages versions in workDir/versions.
+func NewManager(workDir string) *Manager {
+	return &Manager{WorkDir: workDir, Dir: filepath.Join(workDir, "versions")}
+}
+
+// ValidateTag rejects tags that cannot be used as directory names.
+func ValidateTag(tag string) error {
+	if tag == "" || tag == currentLink || strings.HasPrefix(tag, ".") || strings.ContainsAny(tag, `/\`) {
+		return fmt.Errorf("invalid release tag %q", tag)
+	}
+	return nil
+}
+
+func (m *Manager) path(tag string) string {
+	return filepath.Join(m.Dir, tag)
+}
+
+// Current returns the tag of the active version, empty if none is active.
+func (m *Manager) Current() (string, error) {
+	target, err := os.Readlink(filepath.Join(m.Dir, currentLink))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	return filepath.Base(target), err
+}
+
+// List returns the installed versions, newest first.
+func (m *Manager) List() ([]Version, error) {
+	entries, err := os.ReadDir(m.Dir)
+	if os.IsNotExist(err) {
+		return []Version{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	current, err := m.Current()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []Version{}
+	for _, entry := range entries {
+		if !entry.IsDir() || ValidateTag(entry.Name()) != nil {
+			continue
+		}
+		version, err := m.Get(entry.Name())
+		if err != nil {
+			log.Printf("Skipping version %s: %v", entry.Name(), err)
+			continue
+		}
+		version.Active = version.Tag == current
+		result = append(result, version)
+	}
+	sort.Slice(result, func(i, j int) bool { return scrapper.CompareTags(result[i].Tag, result[j].Tag) > 0 })
+	return result, nil
+}
+
+// Get returns the installed version tag.
+func (m *Manager) Get(tag string) (Version, error) {
+	if err := ValidateTag(tag); err != nil {
+		return Version{}, err
+	}
+	info, err := os.Stat(m.path(tag))
+	if err != nil {
+		return Version{}, fmt.Errorf("version %s is not installed", tag)
+	}
+	version := Version{Tag: tag, InstalledAt: info.ModTime()}
+	if data, err := os.ReadFile(filepath.Join(m.path(tag), metadataFile)); err == nil {
+		if err := json.Unmarshal(data, &version); err != nil {
+			return Version{}, fmt.Errorf("error parsing %s of %s: %w", metadataFile, tag, err)
+		}
+	}
+	return version, nil
+}
+
+// Binary returns the path of the motis binary of the active version.
+func (m *Manager) Binary() string {
+	return m.binary(currentLink)
+}
+
+// Executable returns the path of the motis binary of version tag.
+func (m *Manager) Executable(tag string) string {
+	return m.binary(tag)
+}
+
+func (m *Manager) binary(tag string) string {
+	name := "motis"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return filepath.Join(m.Dir, tag, name)
+}
+
+// Install downloads the release archive at url and installs it as tag.
+// An installed version of the same tag is replaced.
+func (m *Manager) Install(tag, url string, progress download.ProgressCallback) (Version, error) {
+	if err := ValidateTag(tag); err != nil {
+		return Version{}, err
+	}
+	if err := os.MkdirAll(m.Dir, 0755); err != nil {
+		return Version{}, err
+	}
+	tmp, err := os.MkdirTemp(m.Dir, ".download-")
+	if err != nil {
+		return Version{}, err
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := download.DownloadFileWithProgress(url, tmp, progress); err != nil {
+		return Version{}, err
+	}
+	return m.InstallArchive(tag, filepath.Join(tmp, download.FileName(url)), url)
+}
+
+// InstallArchive extracts a downloaded release archive as version tag.
+func (m *Manager) InstallArchive(tag, archivePath, url string) (Version, error) {
+	if err := ValidateTag(tag); err != nil {
+		return Version{}, err
+	}
+	if !strings.HasSuffix(archivePath, ".tar.bz2") {
+		return Version{}, fmt.Errorf("unsupported archive %s, only .tar.bz2 releases can be installed", filepath.Base(archivePath))
+	}
+	if err := os.MkdirAll(m.Dir, 0755); err != nil {
+		return Version{}, err
+	}
+
+	// Extract next to the target, so a failed extraction leaves an
+	// installed version of the same tag untouched.
+	staging, err := os.MkdirTemp(m.Dir, ".install-")
+	if err != nil {
+		return Version{}, err
+	}
+	defer os.RemoveAll(staging)
+	// MkdirTemp creates the directory private to the owner.
+	if err := os.Chmod(staging, 0755); err != nil {
+		return Version{}, err
+	}
+	if err := download.ExtractTarBz2(archivePath, staging); err != nil {
+		return Version{}, err
+	}
+
+	version := Version{Tag: tag, Asset: filepath.Base(archivePath), URL: url, InstalledAt: time.Now()}
+	data, err := json.MarshalIndent(version, "", "  ")
+	if err != nil {
+		return Version{}, err
+	}
+	if err := os.WriteFile(filepath.Join(staging, metadataFile), data, 0644); err != nil {
+		return Version{}, err
+	}
+
+	if err := os.RemoveAll(m.path(tag)); err != nil {
+		return Version{}, err
+	}
+	if err := os.Rename(staging, m.path(tag)); err != nil {
+		return Version{}, err
+	}
+	current, _ := m.Current()
+	version.Active = current == tag
+	return version, nil
+}
+
+// Activate makes tag the version run by import and serve. The current
+// pointer is swapped atomically, so switching back is instant.
+func (m *Manager) Activate(tag string) error {
+	if _, err := m.Get(tag); err != nil {
+		return err
+	}
+	link := filepath.Join(m.Dir, currentLink)
+	tmp := link + ".tmp"
+	os.Remove(tmp)
+	if err := os.Symlink(tag, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, link); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return m.linkWorkDir(tag)
+}
+
+// linkWorkDir links every entry of the version into the workspace through
+// the current pointer. Entries owned by the workspace are left alone,
+// except a motis binary extracted there by older releases of this server.
+func (m *Manager) linkWorkDir(tag string) error {
+	entries, err := os.ReadDir(m.path(tag))
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(m.WorkDir, filepath.Join(m.Dir, currentLink))
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == metadataFile {
+			continue
+		}
+		target := filepath.Join(m.WorkDir, name)
+		if info, err := os.Lstat(target); err == nil {
+			if info.Mode()&os.ModeSymlink == 0 && !(name == "motis" && info.Mode().IsRegular()) {
+				log.Printf("Not linking %s of %s: %s exists in the workspace", name, tag, target)
+				continue
+			}
+			if err := os.Remove(target); err != nil {
+				return err
+			}
+		}
+		if err := os.Symlink(filepath.Join(rel, name), target); err != nil {
+			return err
+		}
+	}
+
+	// Drop links to entries the previous version had and this one lacks.
+	links, err := os.ReadDir(m.WorkDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range links {
+		target := filepath.Join(m.WorkDir, entry.Name())
+		dest, err := os.Readlink(target)
+		if err != nil || !strings.HasPrefix(dest, rel+string(filepath.Separator)) {
+			continue
+		}
+		if _, err := os.Stat(target); os.IsNotExist(err) {
+			os.Remove(target)
+		}
+	}
+	return nil
+}
+
+// Remove deletes an installed version. The active version cannot be
+// removed.
+func (m *Manager) Remove(tag string) error {
+	if _, err := m.Get(tag); err != nil {
+		return err
+	}
+	current, err := m.Current()
+	if err != nil {
+		return err
+	}
+	if current == tag {
+		return fmt.Errorf("version %s is active, activate another version first", tag)
+	}
+	return os.RemoveAll(m.path(tag))
+}